@@ -26,6 +26,7 @@ const (
 // Change is used to interact with an manipulate a single change.
 type Change struct {
 	api      *gerrit.Client
+	http     *HTTPClient
 	log      *log.Entry
 	ChangeID string
 	Repo     *Repository
@@ -45,9 +46,42 @@ func (c *Change) Destroy() error {
 	return c.Repo.Destroy()
 }
 
-// Push pushes changes to Gerrit.
+// As returns a copy of the change which performs REST operations, such
+// as ApplyLabel() and Submit(), as username. The account is created if it
+// does not already exist. The copy shares Repo with the original change
+// so only one of them should be destroyed.
+func (c *Change) As(username string) (*Change, error) {
+	client, err := c.http.As(username)
+	if err != nil {
+		return nil, err
+	}
+	api, err := client.Gerrit()
+	if err != nil {
+		return nil, err
+	}
+	return &Change{
+		api:      api,
+		http:     client,
+		log:      c.log.WithField("as", username),
+		ChangeID: c.ChangeID,
+		Repo:     c.Repo,
+	}, nil
+}
+
+// Push pushes changes to Gerrit for review on the branch the change
+// was created for.
 func (c *Change) Push() error {
-	return c.Repo.Push("HEAD:refs/for/master")
+	return c.Repo.Push("")
+}
+
+// Info returns information about the change from Gerrit, including
+// the change number.
+func (c *Change) Info() (*gerrit.ChangeInfo, error) {
+	logger := c.log.WithField("phase", "info")
+	logger.Debug()
+	info, response, err := c.api.Changes.GetChange(c.ChangeID, nil)
+	c.logError(err, logger, response)
+	return info, err
 }
 
 // Add writes a file to the repository but does not commit it. The added or
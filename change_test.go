@@ -52,8 +52,9 @@ func (s *ChangeTest) SetUpTest(c *C) {
 		c.Skip("-short provided")
 	}
 
-	change, err := s.gerrit.CreateChange(
-		generaRandomString(16), generaRandomString(6))
+	project, err := s.gerrit.CreateProject(generaRandomString(16), nil)
+	c.Assert(err, IsNil)
+	change, err := project.CreateChange("", generaRandomString(6))
 	c.Assert(err, IsNil)
 	s.change = change
 }
@@ -159,3 +160,15 @@ func (s *ChangeTest) TestAddFileComment(c *C) {
 	_, err := s.change.AddFileComment("1", relative, 1, "hello")
 	c.Assert(err, IsNil)
 }
+
+func (s *ChangeTest) TestAs(c *C) {
+	s.TestPush(c)
+	reviewer, err := s.change.As("reviewer")
+	c.Assert(err, IsNil)
+	c.Assert(reviewer.Repo, Equals, s.change.Repo)
+	_, err = reviewer.AddTopLevelComment("", "looks good")
+	c.Assert(err, IsNil)
+	info, _, err := reviewer.api.Changes.GetChangeDetail(s.change.ChangeID, nil)
+	c.Assert(err, IsNil)
+	c.Assert(info.Messages[len(info.Messages)-1].Author.Username, Equals, "reviewer")
+}
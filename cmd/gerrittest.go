@@ -29,6 +29,9 @@ var RootCmd = &cobra.Command{
 
 func main() {
 	if err := RootCmd.Execute(); err != nil {
+		if exitErr, ok := err.(*cmd.ExitCodeError); ok {
+			os.Exit(exitErr.Code)
+		}
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -41,4 +44,13 @@ func init() {
 	RootCmd.AddCommand(cmd.Start)
 	RootCmd.AddCommand(cmd.Stop)
 	RootCmd.AddCommand(cmd.GetSSHCommand)
+	RootCmd.AddCommand(cmd.Status)
+	RootCmd.AddCommand(cmd.Exec)
+	RootCmd.AddCommand(cmd.Env)
+	RootCmd.AddCommand(cmd.CreateChange)
+	RootCmd.AddCommand(cmd.GC)
+	RootCmd.AddCommand(cmd.Seed)
+	RootCmd.AddCommand(cmd.SSH)
+	RootCmd.AddCommand(cmd.API)
+	RootCmd.AddCommand(cmd.Config)
 }
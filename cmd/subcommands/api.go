@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+)
+
+// readRequestBody returns the request body for api. path may be "-" to
+// read from stdin or empty if there's no body.
+func readRequestBody(path string, stdin io.Reader) ([]byte, error) {
+	switch path {
+	case "":
+		return nil, nil
+	case "-":
+		return ioutil.ReadAll(stdin)
+	default:
+		return ioutil.ReadFile(path)
+	}
+}
+
+// formatResponseBody indents body if it contains json. Any other content
+// is returned unmodified.
+func formatResponseBody(body []byte) []byte {
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, body, "", "  "); err != nil {
+		return body
+	}
+	indented.WriteString("\n")
+	return indented.Bytes()
+}
+
+// API implements the `api` subcommand.
+var API = &cobra.Command{
+	Use:   "api [flags] METHOD PATH",
+	Short: "Performs an authenticated request against Gerrit's REST API.",
+	Long: "Performs an authenticated request against the REST API of the " +
+		"Gerrit instance described by --json, for example " +
+		"'api GET /changes/?q=status:open'. The /a prefix is added to PATH " +
+		"if it's not present. Json responses are pretty printed. gerrittest " +
+		"exits non-zero if Gerrit responds with an error.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := getString(cmd, "json")
+		if path == "" {
+			return errors.New("--json not provided")
+		}
+		if len(args) != 2 {
+			return errors.New("expected METHOD and PATH")
+		}
+		method := strings.ToUpper(args[0])
+
+		body, err := readRequestBody(getString(cmd, "body"), os.Stdin)
+		if err != nil {
+			return err
+		}
+		gerrit, err := gerrittest.LoadJSON(path)
+		if err != nil {
+			return err
+		}
+		client, err := gerrittest.NewHTTPClient(gerrit.Config, gerrit.HTTPPort)
+		if err != nil {
+			return err
+		}
+
+		response, responseBody, err := client.API(method, args[1], body)
+		if err != nil {
+			return err
+		}
+		if !getBool(cmd, "raw") {
+			responseBody = formatResponseBody(responseBody)
+		}
+		cmd.OutOrStdout().Write(responseBody) // nolint: errcheck
+		if response.StatusCode >= 400 {
+			return fmt.Errorf("%s %s: %s", method, args[1], response.Status)
+		}
+		return nil
+	},
+}
+
+// addAPIFlags adds the flags used by api to cmd.
+func addAPIFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP(
+		"body", "b", "",
+		"A file containing the request body or - to read the body "+
+			"from stdin.")
+	cmd.Flags().Bool(
+		"raw", false,
+		"If provided then print the response without pretty printing it.")
+	addCommonFlags(cmd)
+}
+
+func init() {
+	addAPIFlags(API)
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	"github.com/opalmer/dockertest"
+	"github.com/opalmer/gerrittest"
+	. "gopkg.in/check.v1"
+)
+
+type APITest struct {
+	server *httptest.Server
+	path   string
+
+	// request and body are the last request the server received.
+	request *http.Request
+	body    string
+}
+
+var _ = Suite(&APITest{})
+
+// SetUpTest starts a server which responds to /a/changes/ with json,
+// like Gerrit, including the magic prefix. Any other path returns 404.
+func (s *APITest) SetUpTest(c *C) {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body) // nolint: errcheck
+		s.request = r
+		s.body = string(body)
+		if r.URL.Path != "/a/changes/" {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(")]}'\n{\"a\":[1]}")) // nolint: errcheck
+	}))
+	address := s.server.Listener.Addr().(*net.TCPAddr)
+	cfg := gerrittest.NewConfig()
+	cfg.Username = "admin"
+	cfg.Password = "secret"
+	gerrit := &gerrittest.Gerrit{
+		Config:   cfg,
+		HTTPPort: &dockertest.Port{Address: "127.0.0.1", Public: uint16(address.Port)},
+	}
+	s.path = filepath.Join(c.MkDir(), "gerrit.json")
+	c.Assert(gerrit.WriteJSONFile(s.path), IsNil)
+}
+
+func (s *APITest) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *APITest) run(c *C, args ...string) (string, error) {
+	return execute(c, API, addAPIFlags, args...)
+}
+
+func (s *APITest) TestAPI_JSONFlagNotProvided(c *C) {
+	_, err := s.run(c, "GET", "/changes/")
+	c.Assert(err, ErrorMatches, "--json not provided")
+}
+
+func (s *APITest) TestAPI_BadArgs(c *C) {
+	_, err := s.run(c, "--json", s.path, "GET")
+	c.Assert(err, ErrorMatches, "expected METHOD and PATH")
+}
+
+func (s *APITest) TestAPI(c *C) {
+	output, err := s.run(c, "--json", s.path, "get", "changes/")
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, "{\n  \"a\": [\n    1\n  ]\n}\n")
+	c.Assert(s.request.Method, Equals, http.MethodGet)
+	c.Assert(s.request.URL.Path, Equals, "/a/changes/")
+	username, password, ok := s.request.BasicAuth()
+	c.Assert(ok, Equals, true)
+	c.Assert(username, Equals, "admin")
+	c.Assert(password, Equals, "secret")
+}
+
+func (s *APITest) TestAPI_raw(c *C) {
+	output, err := s.run(c, "--json", s.path, "--raw", "GET", "/a/changes/")
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, `{"a":[1]}`)
+}
+
+func (s *APITest) TestAPI_bodyFromStdin(c *C) {
+	defer setStdin(c, `{"subject": "foo"}`)()
+	_, err := s.run(c, "--json", s.path, "-b", "-", "POST", "/changes/")
+	c.Assert(err, IsNil)
+	c.Assert(s.request.Method, Equals, http.MethodPost)
+	c.Assert(s.body, Equals, `{"subject": "foo"}`)
+}
+
+func (s *APITest) TestAPI_bodyFromFile(c *C) {
+	path := filepath.Join(c.MkDir(), "body.json")
+	c.Assert(ioutil.WriteFile(path, []byte(`{"subject": "bar"}`), 0600), IsNil)
+	_, err := s.run(c, "--json", s.path, "--body", path, "PUT", "/changes/")
+	c.Assert(err, IsNil)
+	c.Assert(s.request.Method, Equals, http.MethodPut)
+	c.Assert(s.body, Equals, `{"subject": "bar"}`)
+}
+
+func (s *APITest) TestAPI_errorStatus(c *C) {
+	output, err := s.run(c, "--json", s.path, "GET", "/projects/missing")
+	c.Assert(err, ErrorMatches, "GET /projects/missing: 404 Not Found")
+	c.Assert(output, Equals, "Not found\n")
+}
+
+func (s *APITest) Test_readRequestBody(c *C) {
+	body, err := readRequestBody("", strings.NewReader("ignored"))
+	c.Assert(err, IsNil)
+	c.Assert(body, IsNil)
+
+	body, err = readRequestBody("-", strings.NewReader("stdin"))
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, "stdin")
+
+	path := filepath.Join(c.MkDir(), "body.json")
+	c.Assert(ioutil.WriteFile(path, []byte("file"), 0600), IsNil)
+	body, err = readRequestBody(path, nil)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, "file")
+}
+
+func (s *APITest) Test_formatResponseBody(c *C) {
+	c.Assert(
+		string(formatResponseBody([]byte(`{"a":[1]}`))),
+		Equals, "{\n  \"a\": [\n    1\n  ]\n}\n")
+	c.Assert(string(formatResponseBody([]byte("Not found"))), Equals, "Not found")
+}
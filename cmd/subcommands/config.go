@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+)
+
+// Config implements the `config` subcommand.
+var Config = &cobra.Command{
+	Use:   "config",
+	Short: "Commands for working with gerrittest's configuration.",
+}
+
+// ConfigPrint implements the `config print` subcommand.
+var ConfigPrint = &cobra.Command{
+	Use:   "print",
+	Short: "Prints the effective configuration.",
+	Long: "Prints the configuration produced by combining the defaults, " +
+		"the config file and GERRITTEST_ environment variables. The output " +
+		"uses the same format as the config file.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := gerrittest.LoadConfig(getString(cmd, "config"))
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(gerrittest.NewConfigFile(cfg), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(data)) // nolint: errcheck
+		return nil
+	},
+}
+
+func init() {
+	ConfigPrint.Flags().StringP(
+		"config", "c", "",
+		"The config file to load. By default $"+
+			gerrittest.ConfigFileEnvironmentVar+" or the first "+
+			strings.Join(gerrittest.ConfigFileNames, ", ")+" in the "+
+			"current directory or its parents is used. The file may be "+
+			"json or yaml.")
+	Config.AddCommand(ConfigPrint)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+	. "gopkg.in/check.v1"
+)
+
+type ConfigTest struct{}
+
+var _ = Suite(&ConfigTest{})
+
+func (s *ConfigTest) TestConfigPrint(c *C) {
+	path := filepath.Join(c.MkDir(), "config.json")
+	c.Assert(ioutil.WriteFile(
+		path, []byte(`{"image": "file", "timeout": "1m"}`), 0600), IsNil)
+	command := &cobra.Command{}
+	command.Flags().String("config", "", "")
+	c.Assert(command.ParseFlags([]string{"--config=" + path}), IsNil)
+	output := &bytes.Buffer{}
+	command.SetOutput(output)
+
+	c.Assert(ConfigPrint.RunE(command, []string{}), IsNil)
+	file := &gerrittest.ConfigFile{}
+	c.Assert(json.Unmarshal(output.Bytes(), file), IsNil)
+	c.Assert(*file.Image, Equals, "file")
+	c.Assert(*file.Timeout, Equals, "1m0s")
+	c.Assert(*file.CleanupContainer, Equals, true)
+}
+
+func (s *ConfigTest) TestConfigPrint_missingFile(c *C) {
+	command := &cobra.Command{}
+	command.Flags().String("config", "", "")
+	c.Assert(command.ParseFlags(
+		[]string{"--config=" + filepath.Join(c.MkDir(), "missing.json")}), IsNil)
+	c.Assert(ConfigPrint.RunE(command, []string{}), NotNil)
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/crewjam/errset"
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+)
+
+// changeFile is a single file to add to a change.
+type changeFile struct {
+	Path    string
+	Mode    os.FileMode
+	Content string
+}
+
+// changeOutput is written by CreateChange once the change has
+// been created.
+type changeOutput struct {
+	Number   int    `json:"number"`
+	URL      string `json:"url"`
+	ChangeID string `json:"change_id"`
+}
+
+// changeLabel is a single label to apply to a change.
+type changeLabel struct {
+	Name  string
+	Value int
+}
+
+// collectFiles returns the files to add to a change. Files are added
+// using their base name while the contents of a directory are added
+// relative to the directory. Any .git directories are skipped.
+func collectFiles(paths []string) ([]*changeFile, error) {
+	files := []*changeFile{}
+	for _, root := range paths {
+		stat, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		base := filepath.Dir(root)
+		if stat.IsDir() {
+			base = root
+		}
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if info.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			relative, err := filepath.Rel(base, path)
+			if err != nil {
+				return err
+			}
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files = append(files, &changeFile{
+				Path:    filepath.ToSlash(relative),
+				Mode:    info.Mode().Perm(),
+				Content: string(content),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// getLabels parses --label into labels sorted by name.
+func getLabels(cmd *cobra.Command) ([]*changeLabel, error) {
+	values, err := getKeyValues(cmd, "label")
+	if err != nil {
+		return nil, err
+	}
+	labels := []*changeLabel{}
+	for _, name := range sortedKeys(values) {
+		value, err := strconv.Atoi(values[name])
+		if err != nil {
+			return nil, fmt.Errorf("--label: invalid value for %s: %q", name, values[name])
+		}
+		labels = append(labels, &changeLabel{Name: name, Value: value})
+	}
+	return labels, nil
+}
+
+// createChange creates, pushes and optionally reviews and submits a
+// change containing files.
+func createChange(cmd *cobra.Command, gerrit *gerrittest.Gerrit, files []*changeFile, labels []*changeLabel) (*changeOutput, error) {
+	name := getString(cmd, "project")
+	project, err := gerrit.GetProject(name)
+	if err == gerrittest.ErrProjectNotFound {
+		project, err = gerrit.CreateProject(name, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	change, err := project.CreateChange(
+		getString(cmd, "branch"), getString(cmd, "subject"))
+	if err != nil {
+		return nil, err
+	}
+	defer change.Destroy() // nolint: errcheck
+
+	for _, file := range files {
+		if err := change.Add(file.Path, file.Mode, file.Content); err != nil {
+			return nil, err
+		}
+	}
+	if err := change.Push(); err != nil {
+		return nil, err
+	}
+	for _, label := range labels {
+		if _, err := change.ApplyLabel("", label.Name, label.Value); err != nil {
+			return nil, err
+		}
+	}
+	for _, comment := range getStringArray(cmd, "comment") {
+		if _, err := change.AddTopLevelComment("", comment); err != nil {
+			return nil, err
+		}
+	}
+	if getBool(cmd, "submit") {
+		if _, err := change.Submit(); err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := change.Info()
+	if err != nil {
+		return nil, err
+	}
+	return &changeOutput{
+		Number: info.Number,
+		URL: fmt.Sprintf("http://%s:%d/%d",
+			gerrit.HTTPPort.Address, gerrit.HTTPPort.Public, info.Number),
+		ChangeID: change.ChangeID,
+	}, nil
+}
+
+// CreateChange implements the `create-change` subcommand.
+var CreateChange = &cobra.Command{
+	Use:   "create-change [flags] [path...]",
+	Short: "Creates a change in a running Gerrit instance.",
+	Long: "Creates a change in the Gerrit instance described by --json. " +
+		"Each path may be a file, which is added to the root of the " +
+		"repository, or a directory whose contents are added relative to " +
+		"the directory. The change number, url and Change-Id are printed " +
+		"as json.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := getString(cmd, "json")
+		if path == "" {
+			return errors.New("--json not provided")
+		}
+		if getString(cmd, "subject") == "" {
+			return errors.New("--subject not provided")
+		}
+		labels, err := getLabels(cmd)
+		if err != nil {
+			return err
+		}
+		files, err := collectFiles(args)
+		if err != nil {
+			return err
+		}
+
+		gerrit, err := gerrittest.NewFromJSON(path)
+		if err != nil {
+			return err
+		}
+		output, err := createChange(cmd, gerrit, files, labels)
+		errs := errset.ErrSet{}
+		errs = append(errs, err)
+		errs = append(errs, gerrit.SSH.Close())
+		if err := errs.ReturnValue(); err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(output, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(data)) // nolint: errcheck
+		return nil
+	},
+}
+
+// addCreateChangeFlags adds the flags used by create-change to cmd.
+func addCreateChangeFlags(cmd *cobra.Command) {
+	cmd.Flags().String(
+		"project", gerrittest.ProjectName,
+		"The project to create the change in. The project will be "+
+			"created if it does not exist.")
+	cmd.Flags().String(
+		"branch", "master",
+		"The branch the change targets. The change is based on the "+
+			"branch's current tip. Branches other than master must "+
+			"already exist.")
+	cmd.Flags().String(
+		"subject", "", "The subject of the change's commit message.")
+	cmd.Flags().StringArray(
+		"label", []string{},
+		"A label to apply after pushing, for example Code-Review=+2. "+
+			"May be provided multiple times.")
+	cmd.Flags().StringArray(
+		"comment", []string{},
+		"A top level comment to add after pushing. May be provided "+
+			"multiple times.")
+	cmd.Flags().Bool(
+		"submit", false,
+		"If provided then submit the change after applying labels and "+
+			"comments.")
+	addCommonFlags(cmd)
+}
+
+func init() {
+	addCreateChangeFlags(CreateChange)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+	. "gopkg.in/check.v1"
+)
+
+type CreateChangeTest struct{}
+
+var _ = Suite(&CreateChangeTest{})
+
+func (s *CreateChangeTest) command(c *C, args ...string) *cobra.Command {
+	command := &cobra.Command{}
+	addCreateChangeFlags(command)
+	c.Assert(command.ParseFlags(args), IsNil)
+	return command
+}
+
+func (s *CreateChangeTest) run(c *C, args ...string) (string, error) {
+	return execute(c, CreateChange, addCreateChangeFlags, args...)
+}
+
+func (s *CreateChangeTest) TestCreateChange_JSONFlagNotProvided(c *C) {
+	_, err := s.run(c, "--subject", "foo")
+	c.Assert(err, ErrorMatches, "--json not provided")
+}
+
+func (s *CreateChangeTest) TestCreateChange_SubjectNotProvided(c *C) {
+	_, err := s.run(c, "--json", "foo.json")
+	c.Assert(err, ErrorMatches, "--subject not provided")
+}
+
+func (s *CreateChangeTest) TestCreateChange_BadLabel(c *C) {
+	_, err := s.run(c, "--json", "foo.json", "--subject", "foo", "--label", "Verified")
+	c.Assert(err, ErrorMatches, `--label: expected key=value, got "Verified"`)
+}
+
+func (s *CreateChangeTest) TestCreateChange_MissingPath(c *C) {
+	missing := filepath.Join(c.MkDir(), "missing")
+	_, err := s.run(c, "--json", "foo.json", missing, "--subject", "foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *CreateChangeTest) TestCreateChange_BadSpec(c *C) {
+	path := filepath.Join(c.MkDir(), "gerrit.json")
+	c.Assert(ioutil.WriteFile(path, []byte("{"), 0600), IsNil)
+	_, err := s.run(c, "--json", path, "--subject", "foo")
+	c.Assert(err, ErrorMatches, "unexpected end of JSON input")
+}
+
+func (s *CreateChangeTest) TestCreateChange(c *C) {
+	if testing.Short() {
+		c.Skip("-short set")
+	}
+	gerrit, err := gerrittest.New(gerrittest.NewConfig())
+	c.Assert(err, IsNil)
+	defer gerrit.Destroy() // nolint: errcheck
+	path := filepath.Join(c.MkDir(), "gerrit.json")
+	c.Assert(gerrit.WriteJSONFile(path), IsNil)
+	readme := filepath.Join(c.MkDir(), "README.md")
+	c.Assert(ioutil.WriteFile(readme, []byte("hello"), 0644), IsNil)
+
+	output, err := s.run(c,
+		"--json", path, "--subject", "Add README", readme,
+		"--label", "Code-Review=+2", "--label", "Verified=+1",
+		"--comment", "Looks good", "--submit")
+	c.Assert(err, IsNil)
+	result := &changeOutput{}
+	c.Assert(json.Unmarshal([]byte(output), result), IsNil)
+	c.Assert(result.URL, Equals, fmt.Sprintf(
+		"http://%s:%d/%d",
+		gerrit.HTTPPort.Address, gerrit.HTTPPort.Public, result.Number))
+	c.Assert(result.ChangeID, Matches, "I[0-9a-f]{40}")
+
+	client, err := gerrit.HTTP.Gerrit()
+	c.Assert(err, IsNil)
+	info, _, err := client.Changes.GetChange(result.ChangeID, nil)
+	c.Assert(err, IsNil)
+	c.Assert(info.Number, Equals, result.Number)
+	c.Assert(info.Status, Equals, "MERGED")
+}
+
+func (s *CreateChangeTest) Test_getLabels(c *C) {
+	command := s.command(c, "--label", "Verified=1", "--label", "Code-Review=+2")
+	labels, err := getLabels(command)
+	c.Assert(err, IsNil)
+	c.Assert(labels, DeepEquals, []*changeLabel{
+		{Name: "Code-Review", Value: 2},
+		{Name: "Verified", Value: 1},
+	})
+}
+
+func (s *CreateChangeTest) Test_getLabels_badValue(c *C) {
+	command := s.command(c, "--label", "Code-Review=two")
+	_, err := getLabels(command)
+	c.Assert(err, ErrorMatches, `--label: invalid value for Code-Review: "two"`)
+}
+
+func (s *CreateChangeTest) Test_collectFiles(c *C) {
+	root := c.MkDir()
+	dir := filepath.Join(root, "dir")
+	c.Assert(os.MkdirAll(filepath.Join(dir, "sub"), 0700), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(dir, ".git"), 0700), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("a"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("x"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(root, "run.sh"), []byte("b"), 0755), IsNil)
+
+	files, err := collectFiles([]string{dir, filepath.Join(root, "run.sh")})
+	c.Assert(err, IsNil)
+	c.Assert(files, DeepEquals, []*changeFile{
+		{Path: "sub/a.txt", Mode: 0644, Content: "a"},
+		{Path: "run.sh", Mode: 0755, Content: "b"},
+	})
+}
+
+func (s *CreateChangeTest) Test_collectFiles_missing(c *C) {
+	_, err := collectFiles([]string{filepath.Join(c.MkDir(), "missing")})
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+)
+
+// ErrUnknownFormat is returned by Env when --format is not one
+// of the supported formats.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// sortedKeys returns the keys of values in sorted order.
+func sortedKeys(values map[string]string) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// shellQuote quotes value for use in bash and other POSIX shells.
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// fishQuote quotes value for use in fish.
+func fishQuote(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	return "'" + strings.Replace(value, "'", `\'`, -1) + "'"
+}
+
+// dotenvQuote quotes value for use in a .env file.
+func dotenvQuote(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	value = strings.Replace(value, "\n", `\n`, -1)
+	return `"` + value + `"`
+}
+
+// formatEnvironment returns the environment for gerrit in the
+// requested format.
+func formatEnvironment(gerrit *gerrittest.Gerrit, format string) (string, error) {
+	if format == "git" {
+		args := []string{}
+		for _, key := range sortedKeys(gerrit.Config.GitConfig) {
+			args = append(args, "-c", shellQuote(
+				fmt.Sprintf("%s=%s", key, gerrit.Config.GitConfig[key])))
+		}
+		return strings.Join(args, " ") + "\n", nil
+	}
+
+	env, err := gerrittest.GetEnvironment(gerrit)
+	if err != nil {
+		return "", err
+	}
+	lines := []string{}
+	for _, key := range sortedKeys(env) {
+		value := env[key]
+		switch format {
+		case "export":
+			lines = append(lines, fmt.Sprintf("export %s=%s", key, shellQuote(value)))
+		case "fish":
+			lines = append(lines, fmt.Sprintf("set -gx %s %s;", key, fishQuote(value)))
+		case "dotenv":
+			lines = append(lines, fmt.Sprintf("%s=%s", key, dotenvQuote(value)))
+		case "github":
+			// Values containing newlines must use the multiline syntax
+			// supported by $GITHUB_ENV.
+			if strings.Contains(value, "\n") {
+				lines = append(lines, fmt.Sprintf(
+					"%s<<GERRITTEST_EOF\n%s\nGERRITTEST_EOF", key, value))
+			} else {
+				lines = append(lines, fmt.Sprintf("%s=%s", key, value))
+			}
+		default:
+			return "", ErrUnknownFormat
+		}
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// Env implements the `env` subcommand.
+var Env = &cobra.Command{
+	Use:   "env",
+	Short: "Prints connection details for a running Gerrit instance.",
+	Long: "Prints connection details for the Gerrit instance described by " +
+		"--json. Supported formats are 'export' (bash, zsh and other POSIX " +
+		"shells), 'fish', 'dotenv' (a .env file), 'github' (for appending " +
+		"to $GITHUB_ENV) and 'git' (-c arguments for git built from the " +
+		"git configuration).",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := getString(cmd, "json")
+		if path == "" {
+			return errors.New("--json not provided")
+		}
+
+		gerrit, err := gerrittest.LoadJSON(path)
+		if err != nil {
+			return err
+		}
+
+		output, err := formatEnvironment(gerrit, getString(cmd, "format"))
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), output) // nolint: errcheck
+		return nil
+	},
+}
+
+func init() {
+	Env.Flags().StringP(
+		"format", "f", "export",
+		"The format to output: export, fish, dotenv, github or git.")
+	addCommonFlags(Env)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/opalmer/dockertest"
+	"github.com/opalmer/gerrittest"
+	. "gopkg.in/check.v1"
+)
+
+type EnvTest struct{}
+
+var _ = Suite(&EnvTest{})
+
+func (s *EnvTest) gerrit() *gerrittest.Gerrit {
+	cfg := gerrittest.NewConfig()
+	cfg.Password = "it's"
+	cfg.SSHKeys = []*gerrittest.SSHKey{{Path: "/tmp/id_rsa", Default: true}}
+	cfg.GitConfig = map[string]string{
+		"user.name":       "admin",
+		"core.sshCommand": "ssh -i /tmp/id_rsa",
+	}
+	return &gerrittest.Gerrit{
+		Config:   cfg,
+		SSHPort:  &dockertest.Port{Address: "localhost", Public: 29418},
+		HTTPPort: &dockertest.Port{Address: "localhost", Public: 8080},
+	}
+}
+
+const sshCommand = "ssh -i /tmp/id_rsa -o UserKnownHostsFile=/dev/null " +
+	"-o StrictHostKeyChecking=no -p 29418 admin@localhost"
+
+func (s *EnvTest) Test_formatEnvironment_export(c *C) {
+	output, err := formatEnvironment(s.gerrit(), "export")
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, ""+
+		"export GERRITTEST_HTTP_URL='http://localhost:8080'\n"+
+		"export GERRITTEST_PASSWORD='it'\\''s'\n"+
+		"export GERRITTEST_SSH_COMMAND='"+sshCommand+"'\n"+
+		"export GERRITTEST_SSH_HOST='localhost'\n"+
+		"export GERRITTEST_SSH_PORT='29418'\n"+
+		"export GERRITTEST_USERNAME='admin'\n")
+}
+
+func (s *EnvTest) Test_formatEnvironment_fish(c *C) {
+	output, err := formatEnvironment(s.gerrit(), "fish")
+	c.Assert(err, IsNil)
+	c.Assert(output, Matches, `(?s)set -gx GERRITTEST_HTTP_URL 'http://localhost:8080';\n`+
+		`set -gx GERRITTEST_PASSWORD 'it\\'s';\n.*`)
+}
+
+func (s *EnvTest) Test_formatEnvironment_dotenv(c *C) {
+	g := s.gerrit()
+	g.Config.Password = `a"b`
+	output, err := formatEnvironment(g, "dotenv")
+	c.Assert(err, IsNil)
+	c.Assert(output, Matches, `(?s)GERRITTEST_HTTP_URL="http://localhost:8080"\n`+
+		`GERRITTEST_PASSWORD="a\\"b"\n.*`)
+}
+
+func (s *EnvTest) Test_formatEnvironment_github(c *C) {
+	g := s.gerrit()
+	g.Config.Password = "a\nb"
+	output, err := formatEnvironment(g, "github")
+	c.Assert(err, IsNil)
+	c.Assert(output, Matches, "(?s)GERRITTEST_HTTP_URL=http://localhost:8080\n"+
+		"GERRITTEST_PASSWORD<<GERRITTEST_EOF\na\nb\nGERRITTEST_EOF\n.*")
+}
+
+func (s *EnvTest) Test_formatEnvironment_git(c *C) {
+	output, err := formatEnvironment(s.gerrit(), "git")
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals,
+		"-c 'core.sshCommand=ssh -i /tmp/id_rsa' -c 'user.name=admin'\n")
+}
+
+func (s *EnvTest) Test_formatEnvironment_unknown(c *C) {
+	_, err := formatEnvironment(s.gerrit(), "yaml")
+	c.Assert(err, Equals, ErrUnknownFormat)
+}
+
+func (s *EnvTest) TestEnv(c *C) {
+	file, err := ioutil.TempFile("", "")
+	c.Assert(err, IsNil)
+	c.Assert(file.Close(), IsNil)
+	defer os.Remove(file.Name()) // nolint: errcheck
+	c.Assert(s.gerrit().WriteJSONFile(file.Name()), IsNil)
+
+	output := &bytes.Buffer{}
+	Env.SetOutput(output)
+	defer Env.SetOutput(nil)
+	c.Assert(Env.Flags().Parse([]string{"--json", file.Name(), "--format", "git"}), IsNil)
+	c.Assert(Env.RunE(Env, []string{}), IsNil)
+	c.Assert(output.String(), Equals,
+		"-c 'core.sshCommand=ssh -i /tmp/id_rsa' -c 'user.name=admin'\n")
+}
+
+func (s *EnvTest) TestEnv_JSONFlagNotProvided(c *C) {
+	c.Assert(Env.Flags().Parse([]string{}), IsNil)
+	c.Assert(Env.Flags().Set("json", ""), IsNil)
+	c.Assert(Env.RunE(Env, []string{}), ErrorMatches, "--json not provided")
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/crewjam/errset"
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+)
+
+// ErrNoCommand is returned by Exec if no command was provided.
+var ErrNoCommand = errors.New("no command provided")
+
+// ExitCodeError is returned by a subcommand when gerrittest should exit
+// with a specific exit code.
+type ExitCodeError struct {
+	Code int
+}
+
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// runCommand runs the provided command with environment variables
+// describing gerrit and returns the command's exit code.
+func runCommand(gerrit *gerrittest.Gerrit, args []string) (int, error) {
+	env, err := gerrittest.GetEnvironment(gerrit)
+	if err != nil {
+		return -1, err
+	}
+
+	command := exec.Command(args[0], args[1:]...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	command.Env = os.Environ()
+	for key, value := range env {
+		command.Env = append(command.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	err = command.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), nil
+		}
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// Exec implements the `exec` subcommand.
+var Exec = &cobra.Command{
+	Use:   "exec [flags] -- command [args...]",
+	Short: "Starts Gerrit, runs a command and then stops Gerrit.",
+	Long: "Starts Gerrit the same way start does and then runs the provided " +
+		"command. The command will be provided with the " +
+		gerrittest.EnvHTTPURL + ", " + gerrittest.EnvSSHHost + ", " +
+		gerrittest.EnvSSHPort + ", " + gerrittest.EnvUsername + ", " +
+		gerrittest.EnvPassword + " and " + gerrittest.EnvSSHCommand +
+		" environment variables. Gerrit is stopped once the command exits " +
+		"and gerrittest exits with the command's exit code.",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrNoCommand
+		}
+		cfg, err := newStartConfig(cmd)
+		if err != nil {
+			return err
+		}
+		gerrit, err := gerrittest.New(cfg)
+		if err != nil {
+			errs := errset.ErrSet{}
+			errs = append(errs, err)
+			errs = append(errs, gerrit.Destroy())
+			return errs.ReturnValue()
+		}
+
+		code := -1
+		if path := getString(cmd, "json"); path != "" {
+			err = gerrit.WriteJSONFile(path)
+		}
+		if err == nil {
+			code, err = runCommand(gerrit, args)
+		}
+		errs := errset.ErrSet{}
+		errs = append(errs, err)
+		errs = append(errs, gerrit.Destroy())
+		if err := errs.ReturnValue(); err != nil {
+			return err
+		}
+		if code != 0 {
+			return &ExitCodeError{Code: code}
+		}
+		return nil
+	},
+}
+
+func init() {
+	addStartFlags(Exec)
+	Exec.Flags().SetInterspersed(false)
+}
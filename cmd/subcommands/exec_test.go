@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"github.com/opalmer/dockertest"
+	"github.com/opalmer/gerrittest"
+	. "gopkg.in/check.v1"
+)
+
+type ExecTest struct{}
+
+var _ = Suite(&ExecTest{})
+
+func (s *ExecTest) gerrit() *gerrittest.Gerrit {
+	cfg := gerrittest.NewConfig()
+	cfg.Password = "secret"
+	cfg.SSHKeys = []*gerrittest.SSHKey{{Path: "/tmp/id_rsa", Default: true}}
+	return &gerrittest.Gerrit{
+		Config:   cfg,
+		SSHPort:  &dockertest.Port{Address: "localhost", Public: 29418},
+		HTTPPort: &dockertest.Port{Address: "localhost", Public: 8080},
+	}
+}
+
+func (s *ExecTest) TestExec_NoCommand(c *C) {
+	c.Assert(Exec.RunE(Exec, []string{}), Equals, ErrNoCommand)
+}
+
+func (s *ExecTest) TestExitCodeError(c *C) {
+	c.Assert((&ExitCodeError{Code: 3}).Error(), Equals, "exit status 3")
+}
+
+func (s *ExecTest) Test_runCommand(c *C) {
+	code, err := runCommand(s.gerrit(), []string{
+		"sh", "-c",
+		`test "$GERRITTEST_HTTP_URL" = "http://localhost:8080" && ` +
+			`test "$GERRITTEST_SSH_PORT" = "29418" && ` +
+			`test "$GERRITTEST_PASSWORD" = "secret"`})
+	c.Assert(err, IsNil)
+	c.Assert(code, Equals, 0)
+}
+
+func (s *ExecTest) Test_runCommand_exitCode(c *C) {
+	code, err := runCommand(s.gerrit(), []string{"sh", "-c", "exit 3"})
+	c.Assert(err, IsNil)
+	c.Assert(code, Equals, 3)
+}
+
+func (s *ExecTest) Test_runCommand_notFound(c *C) {
+	code, err := runCommand(s.gerrit(), []string{"/does/not/exist"})
+	c.Assert(err, NotNil)
+	c.Assert(code, Equals, -1)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+)
+
+// GC implements the `gc` subcommand.
+var GC = &cobra.Command{
+	Use:   "gc",
+	Short: "Removes containers and temporary files left behind by gerrittest.",
+	Long: "Removes containers and temporary files which were left behind " +
+		"by gerrittest processes that exited without cleaning up, for " +
+		"example because a test binary was killed. Containers intended to " +
+		"be reused are not removed. The removed containers and paths are " +
+		"printed as json.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := gerrittest.NewGCConfig()
+		cfg.DryRun = getBool(cmd, "dry-run")
+		cfg.MaxAge = getDuration(cmd, "max-age")
+		if tempDir := getString(cmd, "temp-dir"); tempDir != "" {
+			cfg.TempDir = tempDir
+		}
+
+		result, err := gerrittest.GC(cfg)
+		if result != nil {
+			data, jsonErr := json.MarshalIndent(result, "", "  ")
+			if jsonErr != nil {
+				return jsonErr
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(data)) // nolint: errcheck
+		}
+		return err
+	},
+}
+
+func init() {
+	GC.Flags().Bool(
+		"dry-run", false,
+		"If provided then print what would be removed without removing "+
+			"anything.")
+	GC.Flags().Duration(
+		"max-age", 0,
+		"If non-zero then also remove containers and temporary files "+
+			"older than this even if the process that created them is still "+
+			"running.")
+	GC.Flags().String(
+		"temp-dir", "",
+		"The directory to search for temporary files. Defaults to the "+
+			"system's temporary directory.")
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/crewjam/errset"
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+)
+
+// Seed implements the `seed` subcommand.
+var Seed = &cobra.Command{
+	Use:   "seed [flags] spec.(json|yaml)",
+	Short: "Creates projects, accounts, groups and changes from a spec.",
+	Long: "Creates the projects, accounts, groups and changes described by " +
+		"the provided json or yaml spec in the Gerrit instance described " +
+		"by --json. See gerrittest.SeedSpec for the format of the spec.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := getString(cmd, "json")
+		if path == "" {
+			return errors.New("--json not provided")
+		}
+		if len(args) != 1 {
+			return errors.New("expected exactly one spec")
+		}
+
+		spec, err := gerrittest.ReadSeedSpec(args[0])
+		if err != nil {
+			return err
+		}
+		gerrit, err := gerrittest.NewFromJSON(path)
+		if err != nil {
+			return err
+		}
+		errs := errset.ErrSet{}
+		errs = append(errs, gerrittest.Seed(gerrit, spec))
+		errs = append(errs, gerrit.SSH.Close())
+		return errs.ReturnValue()
+	},
+}
+
+func init() {
+	addCommonFlags(Seed)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/opalmer/gerrittest"
+	. "gopkg.in/check.v1"
+)
+
+type SeedTest struct{}
+
+var _ = Suite(&SeedTest{})
+
+func (s *SeedTest) run(c *C, args ...string) (string, error) {
+	return execute(c, Seed, addCommonFlags, args...)
+}
+
+func (s *SeedTest) write(c *C, name string, data string) string {
+	path := filepath.Join(c.MkDir(), name)
+	c.Assert(ioutil.WriteFile(path, []byte(data), 0600), IsNil)
+	return path
+}
+
+func (s *SeedTest) TestSeed_JSONFlagNotProvided(c *C) {
+	_, err := s.run(c, "spec.json")
+	c.Assert(err, ErrorMatches, "--json not provided")
+}
+
+func (s *SeedTest) TestSeed_SpecNotProvided(c *C) {
+	_, err := s.run(c, "--json", "foo.json")
+	c.Assert(err, ErrorMatches, "expected exactly one spec")
+}
+
+func (s *SeedTest) TestSeed_BadSpec(c *C) {
+	path := s.write(c, "spec.json", `{"changes": [{}]}`)
+	_, err := s.run(c, "--json", "foo.json", path)
+	c.Assert(err, ErrorMatches, `changes\[0\]: subject not provided`)
+}
+
+func (s *SeedTest) TestSeed_BadYAMLSpec(c *C) {
+	path := s.write(c, "spec.yaml", "groups:\n  - members: [jdoe]\n")
+	_, err := s.run(c, "--json", "foo.json", path)
+	c.Assert(err, ErrorMatches, `groups\[0\]: name not provided`)
+}
+
+func (s *SeedTest) TestSeed(c *C) {
+	if testing.Short() {
+		c.Skip("-short set")
+	}
+	instance, err := gerrittest.New(gerrittest.NewConfig())
+	c.Assert(err, IsNil)
+	defer instance.Destroy() // nolint: errcheck
+	path := filepath.Join(c.MkDir(), "gerrit.json")
+	c.Assert(instance.WriteJSONFile(path), IsNil)
+	spec := s.write(c, "spec.yaml", `
+projects:
+  - name: seeded
+    files:
+      README: hello
+changes:
+  - project: seeded
+    subject: Update README
+    patch_sets:
+      - README: one
+    votes:
+      Code-Review: 2
+      Verified: 1
+    status: merged
+`)
+
+	output, err := s.run(c, "--json", path, spec)
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, "")
+	client, err := instance.HTTP.Gerrit()
+	c.Assert(err, IsNil)
+	changes, _, err := client.Changes.QueryChanges(&gerrit.QueryChangeOptions{
+		QueryOptions: gerrit.QueryOptions{Query: []string{"project:seeded"}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(*changes, HasLen, 1)
+	c.Assert((*changes)[0].Status, Equals, "MERGED")
+}
@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"bytes"
 	"flag"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/opalmer/logrusutil"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	. "gopkg.in/check.v1"
 )
 
@@ -32,3 +37,51 @@ func Test(t *testing.T) {
 
 	TestingT(t)
 }
+
+// execute runs a copy of command with args and returns what the command
+// wrote to its output. The copy's flags are added by addFlags, which must
+// be the function the command's init() uses, so each test starts with
+// the command's real flags set to their defaults.
+func execute(c *C, command *cobra.Command, addFlags func(*cobra.Command), args ...string) (string, error) {
+	copied := &cobra.Command{
+		Use:           command.Use,
+		RunE:          command.RunE,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	addFlags(copied)
+	count := 0
+	copied.Flags().VisitAll(func(*pflag.Flag) { count++ })
+	command.Flags().VisitAll(func(expected *pflag.Flag) {
+		count--
+		flag := copied.Flags().Lookup(expected.Name)
+		c.Assert(flag, NotNil, Commentf("--%s", expected.Name))
+		c.Assert(flag.Value.Type(), Equals, expected.Value.Type())
+		c.Assert(flag.DefValue, Equals, expected.DefValue)
+	})
+	c.Assert(count, Equals, 0)
+
+	if args == nil {
+		args = []string{}
+	}
+	output := &bytes.Buffer{}
+	copied.SetOutput(output)
+	copied.SetArgs(args)
+	err := copied.Execute()
+	return output.String(), err
+}
+
+// setStdin replaces os.Stdin with a file containing data until the
+// returned function is called.
+func setStdin(c *C, data string) func() {
+	path := filepath.Join(c.MkDir(), "stdin")
+	c.Assert(ioutil.WriteFile(path, []byte(data), 0600), IsNil)
+	file, err := os.Open(path)
+	c.Assert(err, IsNil)
+	old := os.Stdin
+	os.Stdin = file
+	return func() {
+		os.Stdin = old
+		file.Close() // nolint: errcheck
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/crewjam/errset"
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+)
+
+// sshQuote quotes value so Gerrit's ssh daemon treats it as a single
+// argument. Gerrit does not support escaping quotes so double quotes are
+// used for values which contain single quotes.
+func sshQuote(value string) string {
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+	if !strings.Contains(value, `"`) {
+		return `"` + value + `"`
+	}
+	return shellQuote(value)
+}
+
+// sshCommandLine joins args into a single command line, quoting any
+// arguments which Gerrit would otherwise split.
+func sshCommandLine(args []string) string {
+	quoted := []string{}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"") {
+			arg = sshQuote(arg)
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// SSH implements the `ssh` subcommand.
+var SSH = &cobra.Command{
+	Use:   "ssh [flags] -- command [args...]",
+	Short: "Runs a command over ssh against a running Gerrit instance.",
+	Long: "Runs a command, such as 'gerrit ls-projects', over ssh against " +
+		"the Gerrit instance described by --json. The command's output is " +
+		"streamed and gerrittest exits with the command's exit status. Use " +
+		"--tty to allocate a pseudo terminal, in which case the command may " +
+		"be omitted to start an interactive session.",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := getString(cmd, "json")
+		if path == "" {
+			return errors.New("--json not provided")
+		}
+		tty := getBool(cmd, "tty")
+		if len(args) == 0 && !tty {
+			return ErrNoCommand
+		}
+
+		// Only the ssh keys and port are needed so, unlike NewFromJSON,
+		// this does not require docker or contact the REST API.
+		gerrit, err := gerrittest.LoadJSON(path)
+		if err != nil {
+			return err
+		}
+		keys := []*gerrittest.SSHKey{}
+		for _, key := range gerrit.Config.SSHKeys {
+			loaded, err := gerrittest.LoadSSHKey(key.Path)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, loaded)
+		}
+		gerrit.Config.SSHKeys = keys
+		client, err := gerrittest.NewSSHClient(gerrit.Config, gerrit.SSHPort)
+		if err != nil {
+			return err
+		}
+
+		var code int
+		command := sshCommandLine(args)
+		if tty {
+			code, err = client.Interactive(command, os.Stdin, cmd.OutOrStdout(), os.Stderr)
+		} else {
+			code, err = client.Stream(command, os.Stdin, cmd.OutOrStdout(), os.Stderr)
+		}
+		errs := errset.ErrSet{}
+		errs = append(errs, err)
+		errs = append(errs, client.Close())
+		if err := errs.ReturnValue(); err != nil {
+			return err
+		}
+		if code != 0 {
+			return &ExitCodeError{Code: code}
+		}
+		return nil
+	},
+}
+
+// addSSHFlags adds the flags used by ssh to cmd.
+func addSSHFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP(
+		"tty", "t", false,
+		"If provided then allocate a pseudo terminal for the command.")
+	addCommonFlags(cmd)
+	cmd.Flags().SetInterspersed(false)
+}
+
+func init() {
+	addSSHFlags(SSH)
+}
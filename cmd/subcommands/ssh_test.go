@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/opalmer/dockertest"
+	"github.com/opalmer/gerrittest"
+	"golang.org/x/crypto/ssh"
+	. "gopkg.in/check.v1"
+)
+
+type SSHTest struct {
+	listener net.Listener
+	path     string
+}
+
+var _ = Suite(&SSHTest{})
+
+// handleSession responds to exec requests. 'gerrit version' prints a
+// version, 'exit N' exits with status N and any other command writes
+// stdin followed by the command to stdout.
+func (s *SSHTest) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close() // nolint: errcheck
+	for request := range requests {
+		if request.Type != "exec" {
+			request.Reply(false, nil) // nolint: errcheck
+			continue
+		}
+		request.Reply(true, nil) // nolint: errcheck
+		length := binary.BigEndian.Uint32(request.Payload)
+		command := string(request.Payload[4 : 4+length])
+		code := 0
+		switch {
+		case command == "gerrit version":
+			channel.Write([]byte("gerrit version 2.14.5.1\n")) // nolint: errcheck
+		case strings.HasPrefix(command, "exit "):
+			code, _ = strconv.Atoi(strings.TrimPrefix(command, "exit ")) // nolint: errcheck
+		default:
+			stdin, _ := ioutil.ReadAll(channel)              // nolint: errcheck
+			channel.Write(append(stdin, []byte(command)...)) // nolint: errcheck
+		}
+		status := make([]byte, 4)
+		binary.BigEndian.PutUint32(status, uint32(code))
+		channel.SendRequest("exit-status", false, status) // nolint: errcheck
+		return
+	}
+}
+
+func (s *SSHTest) SetUpTest(c *C) {
+	key, err := gerrittest.GenerateRSAKey()
+	c.Assert(err, IsNil)
+	signer, err := ssh.NewSignerFromKey(key)
+	c.Assert(err, IsNil)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(requests)
+				for newChannel := range channels {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go s.handleSession(channel, requests)
+				}
+			}()
+		}
+	}()
+
+	dir := c.MkDir()
+	sshKey, err := gerrittest.CreateSSHKey(filepath.Join(dir, "id_rsa"))
+	c.Assert(err, IsNil)
+	cfg := gerrittest.NewConfig()
+	cfg.SSHKeys = []*gerrittest.SSHKey{sshKey}
+	address := s.listener.Addr().(*net.TCPAddr)
+	gerrit := &gerrittest.Gerrit{
+		Config:  cfg,
+		SSHPort: &dockertest.Port{Address: "127.0.0.1", Public: uint16(address.Port)},
+	}
+	s.path = filepath.Join(dir, "gerrit.json")
+	c.Assert(gerrit.WriteJSONFile(s.path), IsNil)
+}
+
+func (s *SSHTest) TearDownTest(c *C) {
+	c.Assert(s.listener.Close(), IsNil)
+}
+
+func (s *SSHTest) run(c *C, args ...string) (string, error) {
+	return execute(c, SSH, addSSHFlags, args...)
+}
+
+func (s *SSHTest) TestSSH_JSONFlagNotProvided(c *C) {
+	_, err := s.run(c, "gerrit", "ls-projects")
+	c.Assert(err, ErrorMatches, "--json not provided")
+}
+
+func (s *SSHTest) TestSSH_NoCommand(c *C) {
+	_, err := s.run(c, "--json", s.path)
+	c.Assert(err, Equals, ErrNoCommand)
+}
+
+func (s *SSHTest) TestSSH(c *C) {
+	defer setStdin(c, "hello ")()
+	output, err := s.run(c, "--json", s.path, "gerrit", "review", "-m", "looks good")
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, "hello gerrit review -m 'looks good'")
+}
+
+func (s *SSHTest) TestSSH_flagsAfterCommand(c *C) {
+	defer setStdin(c, "")()
+	output, err := s.run(c, "--json", s.path, "gerrit", "ls-projects", "--json")
+	c.Assert(err, IsNil)
+	c.Assert(output, Equals, "gerrit ls-projects --json")
+}
+
+func (s *SSHTest) TestSSH_exitStatus(c *C) {
+	_, err := s.run(c, "--json", s.path, "exit", "3")
+	c.Assert(err, DeepEquals, &ExitCodeError{Code: 3})
+}
+
+func (s *SSHTest) TestSSH_missingKey(c *C) {
+	gerrit, err := gerrittest.LoadJSON(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(os.Remove(gerrit.Config.SSHKeys[0].Path), IsNil)
+	_, err = s.run(c, "--json", s.path, "gerrit", "ls-projects")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *SSHTest) Test_sshCommandLine(c *C) {
+	c.Assert(
+		sshCommandLine([]string{"gerrit", "review", "-m", "it's done", "1,1"}),
+		Equals, `gerrit review -m "it's done" 1,1`)
+	c.Assert(
+		sshCommandLine([]string{"gerrit", "review", "-m", "looks good"}),
+		Equals, `gerrit review -m 'looks good'`)
+	c.Assert(sshCommandLine([]string{"gerrit", ""}), Equals, "gerrit ''")
+}
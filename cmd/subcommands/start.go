@@ -4,24 +4,36 @@ import (
 	"context"
 	"os"
 	"os/signal"
-	"time"
+	"strings"
 
+	"github.com/crewjam/errset"
+	"github.com/docker/go-units"
 	"github.com/opalmer/dockertest"
 	"github.com/opalmer/gerrittest"
 	"github.com/spf13/cobra"
 )
 
 func addStartFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP(
+		"config", "c", "",
+		"The config file to load. By default $"+
+			gerrittest.ConfigFileEnvironmentVar+" or the first "+
+			strings.Join(gerrittest.ConfigFileNames, ", ")+" in the "+
+			"current directory or its parents is used. The file may be "+
+			"json or yaml. Flags take precedence over environment "+
+			"variables which take precedence over the config file.")
 	cmd.Flags().Duration(
-		"timeout", time.Minute*2,
-		"The maximum amount of time to wait for the service to come up.")
+		"timeout", 0,
+		"The maximum amount of time to wait for the service to come up. "+
+			"Defaults to the configured timeout.")
 	cmd.Flags().BoolP(
 		"no-cleanup", "n", false,
 		"If provided then do not cleanup the container on failure. "+
 			"Useful when debugging changes to the docker image.")
 	cmd.Flags().String(
-		"image", gerrittest.DefaultImage,
-		"The Docker image to spin up Gerrit.")
+		"image", "",
+		"The Docker image to spin up Gerrit. Defaults to the configured "+
+			"image.")
 	cmd.Flags().Uint16(
 		"port-http", dockertest.RandomPort,
 		"The local port to map to Gerrit's REST API. Random by default.")
@@ -42,35 +54,104 @@ func addStartFlags(cmd *cobra.Command) {
 		"project", gerrittest.ProjectName,
 		"The name of the project to create in Gerrit. This will "+
 			"also be used for the remote repo name.")
+	cmd.Flags().String(
+		"site-dir", "",
+		"If provided then store Gerrit's repositories, database and "+
+			"index in this directory so they persist between runs.")
+	cmd.Flags().String(
+		"name", "",
+		"The name to give the container.")
+	cmd.Flags().StringArray(
+		"label", []string{},
+		"Additional labels, in key=value form, to apply to the container.")
+	cmd.Flags().StringArray(
+		"env", []string{},
+		"Additional environment variables, in key=value form, to set "+
+			"inside of the container.")
+	cmd.Flags().String(
+		"memory", "",
+		"The maximum amount of memory the container may use, for "+
+			"example 2g. Gerrit's heap will be limited to 75% of this.")
+	cmd.Flags().Float64(
+		"cpus", 0,
+		"The number of CPUs the container may use.")
+	cmd.Flags().String(
+		"network", "",
+		"The name of an existing Docker network for the container to join.")
 	addCommonFlags(cmd)
 }
 
-func newStartConfig(cmd *cobra.Command) (*gerrittest.Config, error) {
-	// Setup timeout and Ctrl+C handling.
-	timeout := getDuration(cmd, "timeout")
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	interrupts := make(chan os.Signal, 1)
-	signal.Notify(interrupts, os.Interrupt)
-	go func() {
-		defer cancel()
-		for range interrupts {
-			return
-		}
-	}()
+func newStartConfig(cmd *cobra.Command) (*gerrittest.Config, error) { // nolint: gocyclo
+	config, err := gerrittest.LoadConfig(getString(cmd, "config"))
+	if err != nil {
+		return nil, err
+	}
 
-	config := gerrittest.NewConfig()
-	config.Image = getString(cmd, "image")
-	config.PortSSH = getUInt16(cmd, "port-ssh")
-	config.PortHTTP = getUInt16(cmd, "port-http")
-	privateKey := getString(cmd, "private-key")
+	// Flags only override the loaded config when they're provided.
+	if changed(cmd, "timeout") {
+		config.Timeout = getDuration(cmd, "timeout")
+	}
+	if changed(cmd, "image") {
+		config.Image = getString(cmd, "image")
+	}
+	if changed(cmd, "port-ssh") {
+		config.PortSSH = getUInt16(cmd, "port-ssh")
+	}
+	if changed(cmd, "port-http") {
+		config.PortHTTP = getUInt16(cmd, "port-http")
+	}
+	if changed(cmd, "site-dir") {
+		config.SiteDir = getString(cmd, "site-dir")
+	}
+	if changed(cmd, "name") {
+		config.ContainerName = getString(cmd, "name")
+	}
+	if changed(cmd, "cpus") {
+		config.CPUs = getFloat64(cmd, "cpus")
+	}
+	if changed(cmd, "network") {
+		config.Network = getString(cmd, "network")
+	}
+	if memory := getString(cmd, "memory"); memory != "" {
+		bytes, err := units.RAMInBytes(memory)
+		if err != nil {
+			return nil, err
+		}
+		config.Memory = bytes
+	}
+	labels, err := getKeyValues(cmd, "label")
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range labels {
+		config.ContainerLabels[key] = value
+	}
+	environment, err := getKeyValues(cmd, "env")
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range environment {
+		config.Environment[key] = value
+	}
+	if changed(cmd, "password") {
+		config.Password = getString(cmd, "password")
+	}
+	if changed(cmd, "start-only") {
+		config.SkipSetup = getBool(cmd, "start-only")
+	}
+	if changed(cmd, "no-cleanup") {
+		config.CleanupContainer = !getBool(cmd, "no-cleanup")
+	}
 
-	if privateKey != "" {
-		key, err := gerrittest.LoadSSHKey(getString(cmd, "private-key"))
+	// When using a site directory a key will be generated
+	// in the site so it remains valid between runs.
+	if privateKey := getString(cmd, "private-key"); privateKey != "" {
+		key, err := gerrittest.LoadSSHKey(privateKey)
 		if err != nil {
 			return nil, err
 		}
-		config.SSHKeys = append(config.SSHKeys, key)
-	} else {
+		config.SSHKeys = []*gerrittest.SSHKey{key}
+	} else if len(config.SSHKeys) == 0 && config.SiteDir == "" {
 		key, err := gerrittest.NewSSHKey()
 		if err != nil {
 			return nil, err
@@ -78,13 +159,17 @@ func newStartConfig(cmd *cobra.Command) (*gerrittest.Config, error) {
 		config.SSHKeys = append(config.SSHKeys, key)
 	}
 
-	config.Password = getString(cmd, "password")
+	// Setup timeout and Ctrl+C handling.
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		defer cancel()
+		for range interrupts {
+			return
+		}
+	}()
 	config.Context = ctx
-	config.SkipSetup = getBool(cmd, "start-only")
-	if getBool(cmd, "no-cleanup") {
-		config.CleanupContainer = false
-	}
-
 	return config, nil
 }
 
@@ -97,9 +182,16 @@ var Start = &cobra.Command{
 		if err != nil {
 			return err
 		}
+
+		// The container is used by later commands, such as stop, after
+		// this process exits so it should not be considered orphaned.
+		cfg.Detached = true
 		gerrit, err := gerrittest.New(cfg)
 		if err != nil {
-			return gerrit.Destroy()
+			errs := errset.ErrSet{}
+			errs = append(errs, err)
+			errs = append(errs, gerrit.Destroy())
+			return errs.ReturnValue()
 		}
 		return jsonOutput(cmd, gerrit)
 	},
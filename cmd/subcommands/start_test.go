@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
 
 	"github.com/opalmer/gerrittest"
 	"github.com/spf13/cobra"
@@ -53,3 +56,71 @@ func (s *StartTest) Test_newStartConfig(c *C) {
 	c.Assert(cfg.Context, NotNil)
 	c.Assert(cfg.SkipSetup, Equals, true)
 }
+
+func (s *StartTest) Test_newStartConfig_siteDir(c *C) {
+	command := &cobra.Command{}
+	addStartFlags(command)
+	dir := c.MkDir()
+	c.Assert(command.ParseFlags([]string{"--site-dir=" + dir}), IsNil)
+	cfg, err := newStartConfig(command)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.SiteDir, Equals, dir)
+	c.Assert(cfg.SSHKeys, HasLen, 0)
+}
+
+func (s *StartTest) Test_newStartConfig_container(c *C) {
+	command := &cobra.Command{}
+	addStartFlags(command)
+	c.Assert(command.ParseFlags(
+		[]string{
+			"--name=gerrit",
+			"--label=team=ci",
+			"--label=owners=a,b",
+			"--env=A=1",
+			"--env=PATH=/bin:/usr/bin,extra",
+			"--memory=1g",
+			"--cpus=1.5",
+			"--network=ci",
+		}),
+		IsNil)
+	cfg, err := newStartConfig(command)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.ContainerName, Equals, "gerrit")
+	c.Assert(cfg.ContainerLabels, DeepEquals, map[string]string{
+		"team": "ci", "owners": "a,b"})
+	c.Assert(cfg.Environment, DeepEquals, map[string]string{
+		"A": "1", "PATH": "/bin:/usr/bin,extra"})
+	c.Assert(cfg.Memory, Equals, int64(1024*1024*1024))
+	c.Assert(cfg.CPUs, Equals, 1.5)
+	c.Assert(cfg.Network, Equals, "ci")
+}
+
+func (s *StartTest) Test_newStartConfig_badMemory(c *C) {
+	command := &cobra.Command{}
+	addStartFlags(command)
+	c.Assert(command.ParseFlags([]string{"--memory=lots"}), IsNil)
+	_, err := newStartConfig(command)
+	c.Assert(err, NotNil)
+}
+
+func (s *StartTest) Test_newStartConfig_configFile(c *C) {
+	path := filepath.Join(c.MkDir(), "config.json")
+	c.Assert(ioutil.WriteFile(
+		path, []byte(`{"image": "file", "port_ssh": 1000, "timeout": "1m"}`), 0600),
+		IsNil)
+	command := &cobra.Command{}
+	addStartFlags(command)
+	c.Assert(command.ParseFlags(
+		[]string{"--config=" + path, "--image=flag"}), IsNil)
+	cfg, err := newStartConfig(command)
+	c.Assert(err, IsNil)
+	for _, key := range cfg.SSHKeys {
+		defer key.Remove() // nolint: errcheck
+	}
+	c.Assert(cfg.Image, Equals, "flag")
+	c.Assert(cfg.PortSSH, Equals, uint16(1000))
+	c.Assert(cfg.Timeout, Equals, time.Minute)
+	deadline, ok := cfg.Context.Deadline()
+	c.Assert(ok, Equals, true)
+	c.Assert(time.Until(deadline) <= time.Minute, Equals, true)
+}
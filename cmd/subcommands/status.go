@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/opalmer/gerrittest"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ErrUnhealthy is returned by the status command if any of the
+	// health checks failed.
+	ErrUnhealthy = errors.New("one or more health checks failed")
+)
+
+// Status implements the `status` subcommand.
+var Status = &cobra.Command{
+	Use:   "status",
+	Short: "Reports on the health of a running Gerrit instance.",
+	Long: "Checks the container, http, the REST API and ssh then prints a " +
+		"json report. Exits non-zero if any check failed.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := getString(cmd, "json")
+		if path == "" {
+			return errors.New("--json not provided")
+		}
+
+		gerrit, err := gerrittest.LoadJSON(path)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(
+			context.Background(), getDuration(cmd, "timeout"))
+		defer cancel()
+		report := gerrit.Health(ctx)
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		if !report.Healthy {
+			return ErrUnhealthy
+		}
+		return nil
+	},
+}
+
+func init() {
+	Status.Flags().Duration(
+		"timeout", time.Second*30,
+		"The maximum amount of time to wait for the checks to complete.")
+	addCommonFlags(Status)
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "gopkg.in/check.v1"
+)
+
+type StatusTest struct{}
+
+var _ = Suite(&StatusTest{})
+
+func (s *StatusTest) TestStatus_BadSpec(c *C) {
+	file, err := ioutil.TempFile("", "")
+	c.Assert(err, IsNil)
+	defer os.Remove(file.Name()) // nolint: errcheck
+	c.Assert(Status.Flags().Parse([]string{"--json", file.Name()}), IsNil)
+	_, err = file.WriteString("{")
+	c.Assert(err, IsNil)
+	c.Assert(file.Close(), IsNil)
+	c.Assert(Status.RunE(Status, []string{}), ErrorMatches, "unexpected end of JSON input")
+}
+
+func (s *StatusTest) TestStatus_JSONFlagNotProvided(c *C) {
+	c.Assert(Status.Flags().Parse([]string{}), IsNil)
+	c.Assert(Status.Flags().Set("json", ""), IsNil)
+	c.Assert(Status.RunE(Status, []string{}), ErrorMatches, "--json not provided")
+}
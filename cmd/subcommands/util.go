@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/opalmer/gerrittest"
@@ -27,6 +28,11 @@ func exitIf(flag string, err error) bool {
 	return true
 }
 
+// changed returns true if flag was provided on the command line.
+func changed(cmd *cobra.Command, flag string) bool {
+	return cmd.Flags().Changed(flag)
+}
+
 func getBool(cmd *cobra.Command, flag string) bool {
 	value, err := cmd.Flags().GetBool(flag)
 	exitIf(flag, err)
@@ -51,6 +57,31 @@ func getUInt16(cmd *cobra.Command, flag string) uint16 {
 	return value
 }
 
+func getFloat64(cmd *cobra.Command, flag string) float64 {
+	value, err := cmd.Flags().GetFloat64(flag)
+	exitIf(flag, err)
+	return value
+}
+
+func getStringArray(cmd *cobra.Command, flag string) []string {
+	value, err := cmd.Flags().GetStringArray(flag)
+	exitIf(flag, err)
+	return value
+}
+
+// getKeyValues parses a flag containing key=value pairs.
+func getKeyValues(cmd *cobra.Command, flag string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, entry := range getStringArray(cmd, flag) {
+		split := strings.SplitN(entry, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			return nil, fmt.Errorf("--%s: expected key=value, got %q", flag, entry)
+		}
+		values[split[0]] = split[1]
+	}
+	return values, nil
+}
+
 func jsonOutput(cmd *cobra.Command, gerrit *gerrittest.Gerrit) error {
 	path := getString(cmd, "json")
 	if path == "" {
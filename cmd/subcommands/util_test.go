@@ -60,6 +60,30 @@ func (s *UtilTest) TestGetUInt16(c *C) {
 	c.Assert(getUInt16(command, "test"), Equals, uint16(65535))
 }
 
+func (s *UtilTest) TestGetFloat64(c *C) {
+	command := &cobra.Command{}
+	command.Flags().Float64("test", 0, "")
+	c.Assert(command.ParseFlags([]string{"--test=1.5"}), IsNil)
+	c.Assert(getFloat64(command, "test"), Equals, 1.5)
+}
+
+func (s *UtilTest) TestGetKeyValues(c *C) {
+	command := &cobra.Command{}
+	command.Flags().StringArray("test", []string{}, "")
+	c.Assert(command.ParseFlags([]string{"--test=a=1", "--test=b=2=3"}), IsNil)
+	values, err := getKeyValues(command, "test")
+	c.Assert(err, IsNil)
+	c.Assert(values, DeepEquals, map[string]string{"a": "1", "b": "2=3"})
+}
+
+func (s *UtilTest) TestGetKeyValues_invalid(c *C) {
+	command := &cobra.Command{}
+	command.Flags().StringArray("test", []string{}, "")
+	c.Assert(command.ParseFlags([]string{"--test=a"}), IsNil)
+	_, err := getKeyValues(command, "test")
+	c.Assert(err, ErrorMatches, `--test: expected key=value, got "a"`)
+}
+
 func (s *UtilTest) Test_jsonOutput_stdout(c *C) {
 	old := os.Stdout // keep backup of the real stdout
 	r, w, err := os.Pipe()
@@ -5,11 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
-	"github.com/go-ini/ini"
 	"github.com/opalmer/dockertest"
-	log "github.com/sirupsen/logrus"
 )
 
 // Config is used to tell the *runner struct what setup steps
@@ -54,9 +53,73 @@ type Config struct {
 	// CleanupContainer when true will cause the cleanup steps to destroy
 	// the container running Gerrit. This defaults to true.
 	CleanupContainer bool `json:"cleanup_container"`
+
+	// Detached when true indicates the container is intended to outlive
+	// the process which started it, as it does when using the start
+	// subcommand. Detached containers are not labeled with the process
+	// id so GC() only removes them once they're older than
+	// GCConfig.MaxAge.
+	Detached bool `json:"detached"`
+
+	// SnapshotImage is the name of an image produced by Gerrit.Snapshot().
+	// When provided the container will be started from this image and
+	// the setup steps will be skipped. The username and password will be
+	// loaded from the image and the ssh keys from the copies on the host
+	// which Snapshot() made.
+	SnapshotImage string `json:"snapshot_image"`
+
+	// SiteDir is a directory on the host where Gerrit's repositories,
+	// database and index will be stored. The directory is bind mounted
+	// into the container so data survives Destroy(). If the site has
+	// already been setup by a previous run the credentials are loaded from
+	// the site and the setup steps are skipped. The image must support
+	// sites, see ImageRevision.
+	SiteDir string `json:"site_dir"`
+
+	// ContainerName is the name to give the container. If not provided
+	// the runtime will generate a name.
+	ContainerName string `json:"container_name"`
+
+	// ContainerLabels are additional labels to apply to the container.
+	ContainerLabels map[string]string `json:"container_labels"`
+
+	// Environment contains additional environment variables to set
+	// inside of the container.
+	Environment map[string]string `json:"environment"`
+
+	// Memory is the maximum amount of memory, in bytes, the container
+	// may use. Gerrit's heap will be limited to 75% of this value unless
+	// GERRIT_HEAP_LIMIT is set in Environment. Zero means no limit. The
+	// image must support GERRIT_HEAP_LIMIT, see ImageRevision.
+	Memory int64 `json:"memory"`
+
+	// CPUs is the number of CPUs the container may use, for example
+	// 1.5. Zero means no limit.
+	CPUs float64 `json:"cpus"`
+
+	// Network is the name of an existing Docker network for the
+	// container to join. This is useful when Gerrit needs to be
+	// reachable by other containers using ContainerName.
+	Network string `json:"network"`
+
+	// Labels are the labels to add to All-Projects during setup along
+	// with the groups allowed to vote on them. NewConfig() uses
+	// DefaultLabels(). If Labels is nil DefaultLabels() will be used.
+	Labels []*Label `json:"labels"`
+
+	// Runtime is used to create and manage the container Gerrit runs
+	// inside of. If no runtime is provided then Docker will be used.
+	Runtime Runtime `json:"-"`
+
+	// Probes are used to determine when Gerrit is ready after the
+	// container starts. NewConfig() uses DefaultProbes(). If Probes
+	// is nil DefaultProbes() will be used.
+	Probes []ReadinessProbe `json:"-"`
 }
 
-// NewConfig produces a *Config struct with reasonable defaults.
+// NewConfig produces a *Config struct with reasonable defaults. Config
+// files and GERRITTEST_ environment variables are not used, call
+// LoadConfig() to apply them.
 func NewConfig() *Config {
 	image := DefaultImage
 	if value, set := os.LookupEnv(DefaultImageEnvironmentVar); set {
@@ -77,74 +140,20 @@ func NewConfig() *Config {
 		Password:         "",
 		SkipSetup:        false,
 		CleanupContainer: true,
+		Detached:         false,
+		SnapshotImage:    "",
+		SiteDir:          "",
+		ContainerName:    "",
+		ContainerLabels:  map[string]string{},
+		Environment:      map[string]string{},
+		Memory:           0,
+		CPUs:             0,
+		Network:          "",
+		Labels:           DefaultLabels(),
+		Probes:           DefaultProbes(),
 	}
 }
 
-const (
-	accessHeads   = `access "refs/heads/*"`
-	labelVerified = `label "Verified"`
-)
-
-// projectConfig is an internal struct which is used to edit the existing
-// configuration.
-type projectConfig struct {
-	log *log.Entry
-	ini *ini.File
-}
-
-func (c *projectConfig) modifyVerifiedTag() {
-	logger := c.log.WithField("phase", "create-verified-tag")
-	logger.Debug()
-
-	section := c.ini.Section(labelVerified)
-	section.Key("function").SetValue("MaxWithBlock")
-	section.Key("defaultValue").SetValue("0")
-	value := section.Key("value")
-	value.SetValue("-1 Fails")
-	value.AddShadow("0 No Score")  // nolint: errcheck
-	value.AddShadow("+1 Verified") // nolint: errcheck
-}
-
-func (c *projectConfig) modifyAccess() {
-	logger := c.log.WithField("phase", "modify-access")
-	logger.Debug()
-
-	section := c.ini.Section(accessHeads)
-	value := section.Key("label-Verified")
-	value.SetValue("-1..+1 group Administrators")
-	value.AddShadow("-1..+1 group Project Owners") // nolint: errcheck
-}
-
-func (c *projectConfig) write(path string) error {
-	c.modifyVerifiedTag()
-	c.modifyAccess()
-	c.log.WithField("phase", "write").Debug()
-	return c.ini.SaveTo(path)
-}
-
-// newProjectConfig produces a projectConfig struct.
-func newProjectConfig(path string) (*projectConfig, error) {
-	logger := log.WithField("cmp", "project-config")
-	options := ini.LoadOptions{AllowShadows: true}
-
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		logger.WithField("action", "new").Debug()
-		cfg, err := ini.LoadSources(options, []byte(""))
-		if err != nil {
-			return nil, err
-		}
-		return &projectConfig{log: logger, ini: cfg}, nil
-	}
-
-	logger = logger.WithField("path", path)
-	logger.WithField("action", "load").Debug()
-	cfg, err := ini.LoadSources(options, path)
-	if err != nil {
-		return nil, err
-	}
-	return &projectConfig{log: logger, ini: cfg}, nil
-}
-
 // GetSSHCommand returns a string representing the ssh command to
 // run to access the Gerrit container over ssh.
 func GetSSHCommand(gerrit *Gerrit) (string, error) {
@@ -160,3 +169,47 @@ func GetSSHCommand(gerrit *Gerrit) (string, error) {
 
 	return "", errors.New("no default ssh keys present")
 }
+
+const (
+	// EnvHTTPURL is the environment variable GetEnvironment() uses
+	// for the url of Gerrit's web interface and REST API.
+	EnvHTTPURL = "GERRITTEST_HTTP_URL"
+
+	// EnvSSHHost is the environment variable GetEnvironment() uses
+	// for the host running Gerrit's ssh daemon.
+	EnvSSHHost = "GERRITTEST_SSH_HOST"
+
+	// EnvSSHPort is the environment variable GetEnvironment() uses
+	// for the port Gerrit's ssh daemon is listening on.
+	EnvSSHPort = "GERRITTEST_SSH_PORT"
+
+	// EnvUsername is the environment variable GetEnvironment() uses
+	// for the admin username.
+	EnvUsername = "GERRITTEST_USERNAME"
+
+	// EnvPassword is the environment variable GetEnvironment() uses
+	// for the admin user's http password.
+	EnvPassword = "GERRITTEST_PASSWORD"
+
+	// EnvSSHCommand is the environment variable GetEnvironment() uses
+	// for the command returned by GetSSHCommand().
+	EnvSSHCommand = "GERRITTEST_SSH_COMMAND"
+)
+
+// GetEnvironment returns environment variables describing how to connect
+// to the Gerrit instance. The keys are the Env* constants.
+func GetEnvironment(gerrit *Gerrit) (map[string]string, error) {
+	sshCommand, err := GetSSHCommand(gerrit)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		EnvHTTPURL: fmt.Sprintf(
+			"http://%s:%d", gerrit.HTTPPort.Address, gerrit.HTTPPort.Public),
+		EnvSSHHost:    gerrit.SSHPort.Address,
+		EnvSSHPort:    strconv.Itoa(int(gerrit.SSHPort.Public)),
+		EnvUsername:   gerrit.Config.Username,
+		EnvPassword:   gerrit.Config.Password,
+		EnvSSHCommand: sshCommand,
+	}, nil
+}
@@ -0,0 +1,354 @@
+package gerrittest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ConfigFileEnvironmentVar may be set to the path of a config file
+	// which LoadConfig() should use instead of searching for
+	// ConfigFileNames.
+	ConfigFileEnvironmentVar = "GERRITTEST_CONFIG"
+
+	// ConfigUsernameEnvironmentVar and ConfigPasswordEnvironmentVar
+	// set the username and password fields of a ConfigFile. They differ
+	// from the field names so they don't collide with EnvUsername and
+	// EnvPassword which the exec subcommand exports.
+	ConfigUsernameEnvironmentVar = "GERRITTEST_ADMIN_USERNAME"
+	ConfigPasswordEnvironmentVar = "GERRITTEST_ADMIN_PASSWORD"
+)
+
+// ConfigFileNames are the names of the files LoadConfig() searches for
+// in the current directory and its parents when no path is provided. If
+// a directory contains more than one the first in this list is used.
+var ConfigFileNames = []string{
+	".gerrittest.json", ".gerrittest.yaml", ".gerrittest.yml"}
+
+// ConfigFile is the json or yaml representation of a *Config used by
+// config files. Both formats use the json keys below. Each field may also
+// be set using a GERRITTEST_ environment variable named after the field's
+// json key, for example GERRITTEST_PORT_SSH. The image is set using
+// DefaultImageEnvironmentVar and the username and password using
+// ConfigUsernameEnvironmentVar and ConfigPasswordEnvironmentVar.
+// Maps are provided to environment variables as comma separated key=value
+// pairs and lists as comma separated values except for labels which
+// are provided as json. Fields which are not provided do not modify
+// the *Config.
+type ConfigFile struct {
+	Image            *string           `json:"image,omitempty"`
+	PortSSH          *uint16           `json:"port_ssh,omitempty"`
+	PortHTTP         *uint16           `json:"port_http,omitempty"`
+	Timeout          *string           `json:"timeout,omitempty"`
+	GitConfig        map[string]string `json:"git,omitempty"`
+	SSHKeys          []string          `json:"ssh_keys,omitempty"`
+	Username         *string           `json:"username,omitempty"`
+	Password         *string           `json:"password,omitempty"`
+	SkipSetup        *bool             `json:"skip_setup,omitempty"`
+	CleanupContainer *bool             `json:"cleanup_container,omitempty"`
+	Detached         *bool             `json:"detached,omitempty"`
+	SnapshotImage    *string           `json:"snapshot_image,omitempty"`
+	SiteDir          *string           `json:"site_dir,omitempty"`
+	ContainerName    *string           `json:"container_name,omitempty"`
+	ContainerLabels  map[string]string `json:"container_labels,omitempty"`
+	Environment      map[string]string `json:"environment,omitempty"`
+	Memory           *string           `json:"memory,omitempty"`
+	CPUs             *float64          `json:"cpus,omitempty"`
+	Network          *string           `json:"network,omitempty"`
+	Labels           []*Label          `json:"labels,omitempty"`
+}
+
+// Apply applies the fields which are set to cfg. Maps are merged with
+// the existing values while SSHKeys and Labels replace the existing values.
+func (f *ConfigFile) Apply(cfg *Config) error { // nolint: gocyclo
+	if f.Timeout != nil {
+		timeout, err := time.ParseDuration(*f.Timeout)
+		if err != nil {
+			return err
+		}
+		cfg.Timeout = timeout
+	}
+	if f.Memory != nil {
+		memory, err := units.RAMInBytes(*f.Memory)
+		if err != nil {
+			return err
+		}
+		cfg.Memory = memory
+	}
+	if f.SSHKeys != nil {
+		keys := []*SSHKey{}
+		for _, path := range f.SSHKeys {
+			key, err := LoadSSHKey(path)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+		cfg.SSHKeys = keys
+	}
+	if f.Labels != nil {
+		cfg.Labels = f.Labels
+	}
+	if f.Image != nil {
+		cfg.Image = *f.Image
+	}
+	if f.PortSSH != nil {
+		cfg.PortSSH = *f.PortSSH
+	}
+	if f.PortHTTP != nil {
+		cfg.PortHTTP = *f.PortHTTP
+	}
+	if f.Username != nil {
+		cfg.Username = *f.Username
+	}
+	if f.Password != nil {
+		cfg.Password = *f.Password
+	}
+	if f.SkipSetup != nil {
+		cfg.SkipSetup = *f.SkipSetup
+	}
+	if f.CleanupContainer != nil {
+		cfg.CleanupContainer = *f.CleanupContainer
+	}
+	if f.Detached != nil {
+		cfg.Detached = *f.Detached
+	}
+	if f.SnapshotImage != nil {
+		cfg.SnapshotImage = *f.SnapshotImage
+	}
+	if f.SiteDir != nil {
+		cfg.SiteDir = *f.SiteDir
+	}
+	if f.ContainerName != nil {
+		cfg.ContainerName = *f.ContainerName
+	}
+	if f.CPUs != nil {
+		cfg.CPUs = *f.CPUs
+	}
+	if f.Network != nil {
+		cfg.Network = *f.Network
+	}
+	cfg.GitConfig = mergeMaps(cfg.GitConfig, f.GitConfig)
+	cfg.ContainerLabels = mergeMaps(cfg.ContainerLabels, f.ContainerLabels)
+	cfg.Environment = mergeMaps(cfg.Environment, f.Environment)
+	return nil
+}
+
+// mergeMaps returns a copy of base with the values from overrides.
+func mergeMaps(base map[string]string, overrides map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
+
+// NewConfigFile returns a *ConfigFile with every field set from cfg.
+func NewConfigFile(cfg *Config) *ConfigFile {
+	timeout := cfg.Timeout.String()
+	memory := strconv.FormatInt(cfg.Memory, 10)
+	keys := []string{}
+	for _, key := range cfg.SSHKeys {
+		keys = append(keys, key.Path)
+	}
+	return &ConfigFile{
+		Image:            &cfg.Image,
+		PortSSH:          &cfg.PortSSH,
+		PortHTTP:         &cfg.PortHTTP,
+		Timeout:          &timeout,
+		GitConfig:        cfg.GitConfig,
+		SSHKeys:          keys,
+		Username:         &cfg.Username,
+		Password:         &cfg.Password,
+		SkipSetup:        &cfg.SkipSetup,
+		CleanupContainer: &cfg.CleanupContainer,
+		Detached:         &cfg.Detached,
+		SnapshotImage:    &cfg.SnapshotImage,
+		SiteDir:          &cfg.SiteDir,
+		ContainerName:    &cfg.ContainerName,
+		ContainerLabels:  cfg.ContainerLabels,
+		Environment:      cfg.Environment,
+		Memory:           &memory,
+		CPUs:             &cfg.CPUs,
+		Network:          &cfg.Network,
+		Labels:           cfg.Labels,
+	}
+}
+
+// ReadConfigFile reads a *ConfigFile from the yaml or json file at path.
+func ReadConfigFile(path string) (*ConfigFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &ConfigFile{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return file, nil
+}
+
+// configEnvironmentVar returns the environment variable used to set the
+// field with the given json key.
+func configEnvironmentVar(key string) string {
+	switch key {
+	case "image":
+		return DefaultImageEnvironmentVar
+	case "username":
+		return ConfigUsernameEnvironmentVar
+	case "password":
+		return ConfigPasswordEnvironmentVar
+	}
+	return "GERRITTEST_" + strings.ToUpper(key)
+}
+
+// setConfigField parses value and stores it in field.
+func setConfigField(field reflect.Value, value string) error {
+	kind := field.Type().Kind()
+	if kind == reflect.Ptr {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+		kind = field.Kind()
+	}
+	switch kind {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Uint16:
+		parsed, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return json.Unmarshal([]byte(value), field.Addr().Interface())
+		}
+		values := []string{}
+		if value != "" {
+			values = strings.Split(value, ",")
+		}
+		field.Set(reflect.ValueOf(values))
+	case reflect.Map:
+		values := map[string]string{}
+		for _, entry := range strings.Split(value, ",") {
+			if entry == "" {
+				continue
+			}
+			split := strings.SplitN(entry, "=", 2)
+			if len(split) != 2 || split[0] == "" {
+				return fmt.Errorf("expected key=value, got %q", entry)
+			}
+			values[split[0]] = split[1]
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// configFileFromEnvironment returns a *ConfigFile containing the fields
+// set by GERRITTEST_ environment variables.
+func configFileFromEnvironment() (*ConfigFile, error) {
+	file := &ConfigFile{}
+	value := reflect.ValueOf(file).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		key := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+		name := configEnvironmentVar(key)
+		envValue, set := os.LookupEnv(name)
+		if !set {
+			continue
+		}
+		if err := setConfigField(value.Field(i), envValue); err != nil {
+			return nil, fmt.Errorf("$%s: %s", name, err)
+		}
+	}
+	return file, nil
+}
+
+// findConfigFile returns the path to the config file LoadConfig() should
+// use when no path is provided or "" if there is no config file.
+func findConfigFile() (string, error) {
+	if path, set := os.LookupEnv(ConfigFileEnvironmentVar); set {
+		return path, nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range ConfigFileNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig returns a *Config built from the defaults, then the config
+// file and finally GERRITTEST_ environment variables with each layer
+// overriding the previous one. If path is empty then $GERRITTEST_CONFIG
+// is used or, if that's not set, the first of ConfigFileNames found in
+// the current directory or its parents. See ConfigFile for the format of the
+// file and the environment variables.
+func LoadConfig(path string) (*Config, error) {
+	cfg := NewConfig()
+	if path == "" {
+		found, err := findConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		path = found
+	}
+	if path != "" {
+		log.WithFields(log.Fields{
+			"phase": "load-config",
+			"path":  path,
+		}).Debug()
+		file, err := ReadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := file.Apply(cfg); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	env, err := configFileFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, env.Apply(cfg)
+}
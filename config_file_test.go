@@ -0,0 +1,240 @@
+package gerrittest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ConfigFileTest struct {
+	env map[string]string
+	cwd string
+}
+
+var _ = Suite(&ConfigFileTest{})
+
+// configEnvironmentVars returns every environment variable
+// LoadConfig() reads.
+func configEnvironmentVars() []string {
+	names := []string{ConfigFileEnvironmentVar}
+	fileType := reflect.TypeOf(ConfigFile{})
+	for i := 0; i < fileType.NumField(); i++ {
+		key := strings.Split(fileType.Field(i).Tag.Get("json"), ",")[0]
+		names = append(names, configEnvironmentVar(key))
+	}
+	return names
+}
+
+func (s *ConfigFileTest) SetUpTest(c *C) {
+	s.env = map[string]string{}
+	for _, name := range configEnvironmentVars() {
+		if value, set := os.LookupEnv(name); set {
+			s.env[name] = value
+		}
+		c.Assert(os.Unsetenv(name), IsNil)
+	}
+	cwd, err := os.Getwd()
+	c.Assert(err, IsNil)
+	s.cwd = cwd
+	c.Assert(os.Chdir(c.MkDir()), IsNil)
+}
+
+func (s *ConfigFileTest) TearDownTest(c *C) {
+	c.Assert(os.Chdir(s.cwd), IsNil)
+	for _, name := range configEnvironmentVars() {
+		c.Assert(os.Unsetenv(name), IsNil)
+	}
+	for name, value := range s.env {
+		c.Assert(os.Setenv(name, value), IsNil)
+	}
+}
+
+func (s *ConfigFileTest) write(c *C, path string, data string) string {
+	c.Assert(os.MkdirAll(filepath.Dir(path), 0700), IsNil)
+	c.Assert(ioutil.WriteFile(path, []byte(data), 0600), IsNil)
+	return path
+}
+
+func (s *ConfigFileTest) TestLoadConfig_defaults(c *C) {
+	cfg, err := LoadConfig("")
+	c.Assert(err, IsNil)
+	defaults := NewConfig()
+	c.Assert(cfg.Image, Equals, DefaultImage)
+	c.Assert(cfg.Timeout, Equals, defaults.Timeout)
+	c.Assert(cfg.GitConfig, DeepEquals, defaults.GitConfig)
+	c.Assert(cfg.CleanupContainer, Equals, true)
+}
+
+func (s *ConfigFileTest) TestLoadConfig_precedence(c *C) {
+	path := s.write(c, filepath.Join(c.MkDir(), "config.json"), `{
+		"image": "file",
+		"port_ssh": 1000,
+		"timeout": "1m",
+		"git": {"user.name": "file"},
+		"memory": "1g",
+		"cleanup_container": false,
+		"detached": false
+	}`)
+	c.Assert(os.Setenv("GERRITTEST_PORT_SSH", "2000"), IsNil)
+	c.Assert(os.Setenv("GERRITTEST_GIT", "user.email=env@localhost"), IsNil)
+	c.Assert(os.Setenv("GERRITTEST_CONTAINER_LABELS", "a=1,b=2"), IsNil)
+	c.Assert(os.Setenv("GERRITTEST_CPUS", "1.5"), IsNil)
+	c.Assert(os.Setenv("GERRITTEST_DETACHED", "true"), IsNil)
+
+	cfg, err := LoadConfig(path)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Image, Equals, "file")
+	c.Assert(cfg.PortSSH, Equals, uint16(2000))
+	c.Assert(cfg.Timeout, Equals, time.Minute)
+	c.Assert(cfg.GitConfig, DeepEquals, map[string]string{
+		"user.name":  "file",
+		"user.email": "env@localhost",
+	})
+	c.Assert(cfg.ContainerLabels, DeepEquals, map[string]string{"a": "1", "b": "2"})
+	c.Assert(cfg.Memory, Equals, int64(1024*1024*1024))
+	c.Assert(cfg.CPUs, Equals, 1.5)
+	c.Assert(cfg.CleanupContainer, Equals, false)
+	c.Assert(cfg.Detached, Equals, true)
+}
+
+func (s *ConfigFileTest) TestLoadConfig_findConfigFile(c *C) {
+	root := c.MkDir()
+	s.write(c, filepath.Join(root, ConfigFileNames[0]), `{"image": "found"}`)
+	dir := filepath.Join(root, "a", "b")
+	c.Assert(os.MkdirAll(dir, 0700), IsNil)
+	c.Assert(os.Chdir(dir), IsNil)
+	cfg, err := LoadConfig("")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Image, Equals, "found")
+
+	// $GERRITTEST_CONFIG takes precedence over searching.
+	path := s.write(c, filepath.Join(c.MkDir(), "config.json"), `{"image": "env"}`)
+	c.Assert(os.Setenv(ConfigFileEnvironmentVar, path), IsNil)
+	cfg, err = LoadConfig("")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Image, Equals, "env")
+}
+
+func (s *ConfigFileTest) TestLoadConfig_errors(c *C) {
+	path := s.write(c, filepath.Join(c.MkDir(), "config.json"), `{"timeout": "soon"}`)
+	_, err := LoadConfig(path)
+	c.Assert(err, ErrorMatches, ".*config.json: time: invalid duration.*")
+
+	c.Assert(os.Setenv("GERRITTEST_PORT_SSH", "abc"), IsNil)
+	_, err = LoadConfig("")
+	c.Assert(err, ErrorMatches, `\$GERRITTEST_PORT_SSH: .*invalid syntax`)
+}
+
+func (s *ConfigFileTest) TestLoadConfig_yaml(c *C) {
+	for _, name := range []string{".gerrittest.yaml", ".gerrittest.yml"} {
+		dir := c.MkDir()
+		s.write(c, filepath.Join(dir, name), `
+image: found
+port_ssh: 1000
+timeout: 1m
+git:
+  user.name: yaml
+ssh_keys: []
+detached: true
+environment:
+  A: "1"
+`)
+		c.Assert(os.Chdir(dir), IsNil)
+		cfg, err := LoadConfig("")
+		c.Assert(err, IsNil)
+		c.Assert(cfg.Image, Equals, "found")
+		c.Assert(cfg.PortSSH, Equals, uint16(1000))
+		c.Assert(cfg.Timeout, Equals, time.Minute)
+		c.Assert(cfg.GitConfig["user.name"], Equals, "yaml")
+		c.Assert(cfg.Environment, DeepEquals, map[string]string{"A": "1"})
+		c.Assert(cfg.Detached, Equals, true)
+	}
+
+	path := s.write(c, filepath.Join(c.MkDir(), "config.yaml"), "port_ssh: [")
+	_, err := LoadConfig(path)
+	c.Assert(err, ErrorMatches, ".*config.yaml: .*")
+}
+
+func (s *ConfigFileTest) TestLoadConfig_credentials(c *C) {
+	for _, name := range []string{EnvUsername, EnvPassword} {
+		if value, set := os.LookupEnv(name); set {
+			defer os.Setenv(name, value) // nolint: errcheck
+		}
+		defer os.Unsetenv(name) // nolint: errcheck
+	}
+
+	// The variables exported by the exec subcommand are not used.
+	c.Assert(os.Setenv(EnvUsername, "exported"), IsNil)
+	c.Assert(os.Setenv(EnvPassword, "exported"), IsNil)
+	cfg, err := LoadConfig("")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Username, Equals, "admin")
+	c.Assert(cfg.Password, Equals, "")
+
+	c.Assert(os.Setenv(ConfigUsernameEnvironmentVar, "jdoe"), IsNil)
+	c.Assert(os.Setenv(ConfigPasswordEnvironmentVar, "secret"), IsNil)
+	cfg, err = LoadConfig("")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Username, Equals, "jdoe")
+	c.Assert(cfg.Password, Equals, "secret")
+}
+
+func (s *ConfigFileTest) TestNewConfig_defaults(c *C) {
+	s.write(c, ConfigFileNames[0], `{"image": "file"}`)
+	c.Assert(os.Setenv("GERRITTEST_SKIP_SETUP", "true"), IsNil)
+	cfg := NewConfig()
+	c.Assert(cfg.SkipSetup, Equals, false)
+	c.Assert(cfg.Image, Equals, DefaultImage)
+}
+
+func (s *ConfigFileTest) TestNewConfigFile(c *C) {
+	key, err := CreateSSHKey(filepath.Join(c.MkDir(), "id_rsa"))
+	c.Assert(err, IsNil)
+	cfg := NewConfig()
+	cfg.Image = "image"
+	cfg.Timeout = time.Second * 30
+	cfg.Memory = 1024
+	cfg.SSHKeys = []*SSHKey{key}
+	cfg.Environment = map[string]string{"A": "1"}
+	cfg.Detached = true
+
+	applied := NewConfig()
+	c.Assert(NewConfigFile(cfg).Apply(applied), IsNil)
+	c.Assert(applied.Image, Equals, "image")
+	c.Assert(applied.Timeout, Equals, time.Second*30)
+	c.Assert(applied.Memory, Equals, int64(1024))
+	c.Assert(applied.SSHKeys, HasLen, 1)
+	c.Assert(applied.SSHKeys[0].Path, Equals, key.Path)
+	c.Assert(applied.Environment, DeepEquals, cfg.Environment)
+	c.Assert(applied.Detached, Equals, true)
+}
+
+func (s *ConfigFileTest) TestLoadConfig_labels(c *C) {
+	cfg, err := LoadConfig("")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Labels, DeepEquals, DefaultLabels())
+
+	path := s.write(c, filepath.Join(c.MkDir(), "config.json"), `{
+		"labels": [{
+			"name": "Lint",
+			"function": "NoBlock",
+			"values": [{"value": -1, "description": "Fails"}, {"value": 1, "description": "Passes"}],
+			"groups": ["CI"]
+		}]
+	}`)
+	cfg, err = LoadConfig(path)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Labels, HasLen, 1)
+	c.Assert(cfg.Labels[0].Name, Equals, "Lint")
+	c.Assert(cfg.Labels[0].Groups, DeepEquals, []string{"CI"})
+
+	c.Assert(os.Setenv("GERRITTEST_LABELS", `[{"name": "QA-Review"}]`), IsNil)
+	cfg, err = LoadConfig(path)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Labels, DeepEquals, []*Label{{Name: "QA-Review"}})
+}
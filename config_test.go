@@ -1,10 +1,8 @@
 package gerrittest
 
 import (
-	"io/ioutil"
 	"os"
 
-	"github.com/go-ini/ini"
 	"github.com/opalmer/dockertest"
 	. "gopkg.in/check.v1"
 )
@@ -37,50 +35,6 @@ func (s *ConfigTest) TestNewConfigOverride(c *C) {
 	c.Assert(cfg.Image, Equals, "override")
 }
 
-func (s *ConfigTest) testWrittenConfig(c *C, path string) {
-	cfg, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, path)
-	c.Assert(err, IsNil)
-	heads := cfg.Section(accessHeads)
-	c.Assert(
-		heads.Key("label-Verified").ValueWithShadows(), DeepEquals,
-		[]string{"-1..+1 group Administrators", "-1..+1 group Project Owners"})
-	verifiedLabel := cfg.Section(labelVerified)
-	c.Assert(verifiedLabel.Key("function").Value(), Equals, "MaxWithBlock")
-	c.Assert(verifiedLabel.Key("defaultValue").Value(), Equals, "0")
-	c.Assert(
-		verifiedLabel.Key("value").ValueWithShadows(), DeepEquals,
-		[]string{"-1 Fails", "0 No Score", "+1 Verified"})
-}
-
-func (s *ConfigTest) Test_projectConfig_newProjectConfig_missingFile(c *C) {
-	file, err := ioutil.TempFile("", "")
-	defer os.Remove(file.Name()) // nolint: errcheck
-	c.Assert(err, IsNil)
-	c.Assert(file.Close(), IsNil)
-	c.Assert(os.Remove(file.Name()), IsNil)
-	cfg, err := newProjectConfig(file.Name())
-	c.Assert(err, IsNil)
-	c.Assert(cfg.write(file.Name()), IsNil)
-	s.testWrittenConfig(c, file.Name())
-}
-
-func (s *ConfigTest) Test_projectConfig_newProjectConfig_existingConifg(c *C) {
-	file, err := ioutil.TempFile("", "")
-	c.Assert(err, IsNil)
-	_, err = file.WriteString(`
-[label "Verified"]
-default = 1
-	`)
-	c.Assert(err, IsNil)
-	defer os.Remove(file.Name()) // nolint: errcheck
-	c.Assert(err, IsNil)
-	c.Assert(file.Close(), IsNil)
-	cfg, err := newProjectConfig(file.Name())
-	c.Assert(err, IsNil)
-	c.Assert(cfg.write(file.Name()), IsNil)
-	s.testWrittenConfig(c, file.Name())
-}
-
 func (s *ConfigTest) getConfigForGetSSHCommandTest() *Gerrit {
 	return &Gerrit{
 		Config: &Config{
@@ -94,6 +48,10 @@ func (s *ConfigTest) getConfigForGetSSHCommandTest() *Gerrit {
 			Address: "1.2.3.4",
 			Public:  65535,
 		},
+		HTTPPort: &dockertest.Port{
+			Address: "localhost",
+			Public:  8080,
+		},
 	}
 }
 
@@ -113,3 +71,26 @@ func (s *ConfigTest) TestGetSSHCommandNoDefaultKeys(c *C) {
 	c.Assert(err, ErrorMatches, "no default ssh keys present")
 	c.Assert(cmd, Equals, "")
 }
+
+func (s *ConfigTest) TestGetEnvironment(c *C) {
+	g := s.getConfigForGetSSHCommandTest()
+	g.Config.Password = "secret"
+	env, err := GetEnvironment(g)
+	c.Assert(err, IsNil)
+	c.Assert(env, DeepEquals, map[string]string{
+		EnvHTTPURL:  "http://localhost:8080",
+		EnvSSHHost:  "1.2.3.4",
+		EnvSSHPort:  "65535",
+		EnvUsername: "testing",
+		EnvPassword: "secret",
+		EnvSSHCommand: "ssh -i /tmp/id_rsa -o UserKnownHostsFile=/dev/null " +
+			"-o StrictHostKeyChecking=no -p 65535 testing@1.2.3.4",
+	})
+}
+
+func (s *ConfigTest) TestGetEnvironmentNoDefaultKeys(c *C) {
+	g := s.getConfigForGetSSHCommandTest()
+	g.Config.SSHKeys[0].Default = false
+	_, err := GetEnvironment(g)
+	c.Assert(err, ErrorMatches, "no default ssh keys present")
+}
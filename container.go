@@ -1,12 +1,17 @@
 package gerrittest
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
-	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +26,25 @@ var (
 	// NewConfig(). This may be overridden with the $GERRITTEST_DOCKER_IMAGE
 	// environment variable.
 	DefaultImage = "opalmer/gerrittest:2.14.5.1"
+
+	// ImageRevision is the minimum value of the LabelImageRevision label
+	// an image must have to be used with Config.SiteDir or Config.Memory.
+	// Older images, including DefaultImage, can't initialize an empty
+	// site or limit the size of the JVM's heap.
+	ImageRevision = 1
+
+	// ReuseTimeout is the amount of time AcquireContainer will wait for
+	// an existing container to respond before skipping it.
+	ReuseTimeout = time.Second * 5
+
+	// SiteMounts are the directories in Config.SiteDir that will be bind
+	// mounted into the Gerrit site inside of the container. Together these
+	// contain the repositories, database and search index.
+	SiteMounts = []string{"git", "db", "index"}
+
+	// LogLines is the number of lines from the end of the container's log
+	// to include in a *ContainerError.
+	LogLines = 50
 )
 
 const (
@@ -35,6 +59,51 @@ const (
 	// ExportedSSHPort is the port exported by the docker container
 	// where the SSHPort service is running.
 	ExportedSSHPort = 29418
+
+	// LabelReuse is the label applied to containers started by
+	// AcquireContainer. Only containers with this label will be
+	// considered for reuse.
+	LabelReuse = "gerrittest.reuse"
+
+	// LabelSite is the label applied to containers which bind mount
+	// Config.SiteDir. It contains the absolute path to the site.
+	LabelSite = "gerrittest.site"
+
+	// SitePath is the path to the Gerrit site inside of the container.
+	SitePath = "/var/gerrit"
+
+	// LabelPID is the label applied to containers started by
+	// NewContainer. It contains the id of the process which started
+	// the container and is used by GC() to locate orphaned containers.
+	// The label is not applied if the container is intended to outlive
+	// the process, see Config.Detached and Config.CleanupContainer.
+	LabelPID = "gerrittest.pid"
+
+	// LabelHost is the label applied to containers started by
+	// NewContainer. It contains the hostname of the machine which
+	// started the container.
+	LabelHost = "gerrittest.host"
+
+	// LabelCreated is the label applied to containers started by
+	// NewContainer. It contains the time the container was requested
+	// in RFC 3339 format.
+	LabelCreated = "gerrittest.created"
+
+	// LabelSSHKeys is the label applied to containers started by
+	// NewContainer. It contains a json list of the paths to the ssh
+	// keys in Config.SSHKeys so GC() does not remove keys which are
+	// still needed to access a container.
+	LabelSSHKeys = "gerrittest.ssh-keys"
+
+	// LabelImageRevision is the label applied to images built from
+	// docker/Dockerfile. It contains the IMAGE_REVISION the image was
+	// built with, see ImageRevision.
+	LabelImageRevision = "gerrittest.image-revision"
+
+	// LabelState is the label applied to images produced by
+	// Gerrit.Snapshot(). It contains the json produced by
+	// Gerrit.WriteJSONFile().
+	LabelState = "gerrittest.state"
 )
 
 func newPort(public uint16, private uint16) (*dockertest.Port, error) {
@@ -66,88 +135,127 @@ func newPort(public uint16, private uint16) (*dockertest.Port, error) {
 	}, nil
 }
 
-func waitPort(ctx context.Context, port *dockertest.Port, errs chan error) {
-	addr := fmt.Sprintf("%s:%d", port.Address, port.Public)
-	logger := log.WithFields(log.Fields{
-		"cmp":   "container",
-		"phase": "port-wait",
-		"addr":  addr,
-	})
-	logger.WithField("task", "begin").Debug()
-	started := time.Now()
-	ticker := time.NewTicker(time.Millisecond * 200)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			errs <- ctx.Err()
-			return
-		case <-ticker.C:
-			conn, err := net.Dial("tcp", addr)
-			if err != nil {
-				continue
-			}
-			logger.WithFields(log.Fields{
-				"task":    "done",
-				"elapsed": time.Since(started),
-			})
-			errs <- conn.Close()
-			return
+// ContainerInput is passed to Runtime.Start() and describes the
+// container to create.
+type ContainerInput struct {
+	*dockertest.ClientInput
+
+	// Binds contains paths on the host to bind mount into the
+	// container using the format host-path:container-path.
+	Binds []string
+
+	// Name is the name of the container. If empty the runtime
+	// will choose a name.
+	Name string
+
+	// Memory is the memory limit of the container in bytes.
+	Memory int64
+
+	// NanoCPUs is the CPU limit of the container in units
+	// of 10^-9 CPUs.
+	NanoCPUs int64
+
+	// Network is the name of a network the container should join.
+	Network string
+}
+
+// bindSite bind mounts the site sub-directories listed in SiteMounts
+// from siteDir into the container. The directories will be created if
+// they do not exist. The container is labeled with LabelSite so
+// AcquireContainer will only reuse containers using the same site.
+func (i *ContainerInput) bindSite(siteDir string) error {
+	siteDir, err := filepath.Abs(siteDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range SiteMounts {
+		path := filepath.Join(siteDir, name)
+		if err := os.MkdirAll(path, 0700); err != nil {
+			return err
 		}
+		i.Binds = append(i.Binds, fmt.Sprintf("%s:%s/%s", path, SitePath, name))
 	}
+	i.SetLabel(LabelSite, siteDir)
+	return nil
 }
 
-func waitHTTP(ctx context.Context, port *dockertest.Port, errs chan error) {
-	url := fmt.Sprintf("http://%s:%d/", port.Address, port.Public)
-	logger := log.WithFields(log.Fields{
-		"cmp":   "container",
-		"phase": "wait-port",
-		"url":   url,
-	})
-	logger.WithField("task", "begin").Debug()
-	started := time.Now()
-	ticker := time.NewTicker(time.Millisecond * 200)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			errs <- ctx.Err()
-			return
-		case <-ticker.C:
-			response, err := http.Get(url)
-			if err != nil {
-				continue
-			}
-			if response.StatusCode != http.StatusOK {
-				continue
-			}
-			logger.WithFields(log.Fields{
-				"task":    "end",
-				"elapsed": time.Since(started),
-			})
-			errs <- nil
-			return
-		}
+// setOwner applies the LabelPID, LabelHost, LabelCreated and LabelSSHKeys
+// labels which describe the current process. LabelPID is only applied if
+// the container should be removed when the current process exits.
+func (i *ContainerInput) setOwner(cfg *Config) error {
+	hostname, _ := os.Hostname() // nolint: errcheck
+	if cfg.CleanupContainer && !cfg.Detached {
+		i.SetLabel(LabelPID, strconv.Itoa(os.Getpid()))
 	}
+	i.SetLabel(LabelHost, hostname)
+	i.SetLabel(LabelCreated, time.Now().UTC().Format(time.RFC3339))
+
+	paths := []string{}
+	for _, key := range cfg.SSHKeys {
+		paths = append(paths, key.Path)
+	}
+	data, err := json.Marshal(paths)
+	if err != nil {
+		return err
+	}
+	i.SetLabel(LabelSSHKeys, string(data))
+	return nil
+}
+
+// heapLimit returns the value for GERRIT_HEAP_LIMIT given the
+// container's memory limit in bytes.
+func heapLimit(memory int64) string {
+	return fmt.Sprintf("%dm", memory/1024/1024*3/4)
 }
 
-func getDockerClientInput(http uint16, ssh uint16, image string) (*dockertest.ClientInput, error) {
-	httpPort, err := newPort(http, ExportedHTTPPort)
+// getDockerClientInput returns the *ContainerInput to use to start a
+// container for the provided config.
+func getDockerClientInput(cfg *Config) (*ContainerInput, error) {
+	httpPort, err := newPort(cfg.PortHTTP, ExportedHTTPPort)
 	if err != nil {
 		return nil, err
 	}
-	sshPort, err := newPort(ssh, ExportedSSHPort)
+	sshPort, err := newPort(cfg.PortSSH, ExportedSSHPort)
 	if err != nil {
 		return nil, err
 	}
 
-	image = GetDockerImage(image)
-	input := dockertest.NewClientInput(image)
+	image := GetDockerImage(containerImage(cfg))
+	input := &ContainerInput{
+		ClientInput: dockertest.NewClientInput(image),
+		Binds:       []string{},
+		Name:        cfg.ContainerName,
+		Memory:      cfg.Memory,
+		NanoCPUs:    int64(cfg.CPUs * 1e9),
+		Network:     cfg.Network,
+	}
 	input.Ports.Add(httpPort)
 	input.Ports.Add(sshPort)
+	for key, value := range cfg.ContainerLabels {
+		input.SetLabel(key, value)
+	}
+
+	// Sort the environment so the input is consistent between calls.
+	keys := []string{}
+	for key := range cfg.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		input.AddEnvironmentVar(key, cfg.Environment[key])
+	}
+	if _, set := cfg.Environment["GERRIT_HEAP_LIMIT"]; !set && cfg.Memory > 0 {
+		input.AddEnvironmentVar("GERRIT_HEAP_LIMIT", heapLimit(cfg.Memory))
+	}
 	input.AddEnvironmentVar(
 		"GERRIT_CANONICAL_URL",
 		fmt.Sprintf("http://127.0.0.1:%d/", httpPort.Public))
+
+	if cfg.SiteDir != "" {
+		if err := input.bindSite(cfg.SiteDir); err != nil {
+			return nil, err
+		}
+	}
 	return input, nil
 }
 
@@ -164,113 +272,427 @@ func GetDockerImage(image string) string {
 	return DefaultImage
 }
 
+// ContainerError is returned when Gerrit fails to start or could not be
+// setup. It includes the last lines of the container's console log which
+// usually explains why Gerrit failed.
+type ContainerError struct {
+	// Err is the original error.
+	Err error
+
+	// ID is the id of the container.
+	ID string
+
+	// Logs contains the last LogLines lines of the container's log.
+	Logs []string
+}
+
+// Error returns the original error followed by the container's log.
+func (e *ContainerError) Error() string {
+	return fmt.Sprintf(
+		"%s\n--- last %d lines of log for container %s ---\n%s",
+		e.Err, len(e.Logs), e.ID, strings.Join(e.Logs, "\n"))
+}
+
 // Container stores information about a Gerrit instance running inside of
 // a container.
 type Container struct {
-	ctx    context.Context
-	Docker *dockertest.DockerClient `json:"-"`
-	HTTP   *dockertest.Port         `json:"http"`
-	SSH    *dockertest.Port         `json:"ssh"`
-	Image  string                   `json:"image"`
-	ID     string                   `json:"id"`
+	ctx     context.Context
+	Runtime Runtime          `json:"-"`
+	HTTP    *dockertest.Port `json:"http"`
+	SSH     *dockertest.Port `json:"ssh"`
+	Image   string           `json:"image"`
+	ID      string           `json:"id"`
 }
 
-// Terminate will terminate and remove the running container.
-func (c *Container) Terminate() error {
-	return c.Docker.RemoveContainer(c.ctx, c.ID)
+// runtime returns the Runtime for the container. If a Runtime was not
+// provided, such as when the container was loaded from json, a Runtime
+// using Docker will be created.
+func (c *Container) runtime() (Runtime, error) {
+	if c.Runtime == nil {
+		runtime, err := NewDockerRuntime()
+		if err != nil {
+			return nil, err
+		}
+		c.Runtime = runtime
+	}
+	return c.Runtime, nil
 }
 
-// NewContainer will create a new container using dockertest and return
-// it. If you prefer to use an existing container use one of the LoadContainer*
-// functions instead. This function will not return until the container has
-// started and is listening on the requested ports.
-func NewContainer(parent context.Context, http uint16, ssh uint16, image string) (*Container, error) {
-	image = GetDockerImage(image)
-	logger := log.WithFields(log.Fields{
-		"cmp": "container",
-	})
-	logger.WithField("image", image).Debug()
+// getContext returns the context for the container, falling back
+// to context.Background() if one was never set.
+func (c *Container) getContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
 
-	input, err := getDockerClientInput(http, ssh, image)
+// Terminate will terminate and remove the running container. The
+// container's context is not used because the container should be
+// removed even if the context has been cancelled or timed out.
+func (c *Container) Terminate() error {
+	runtime, err := c.runtime()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return runtime.Remove(context.Background(), c.ID)
+}
 
-	client, err := dockertest.NewClient()
+// Commit creates a new image, tagged with the provided reference, from the
+// current state of the container. The provided labels will be applied to
+// the image. The id of the new image is returned.
+func (c *Container) Commit(reference string, labels map[string]string) (string, error) {
+	runtime, err := c.runtime()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	return runtime.Commit(c.getContext(), c.ID, reference, labels)
+}
 
-	startLog := logger.WithField("phase", "service")
-	service := &dockertest.Service{
-		Input:  input,
-		Client: client,
-		Name:   "gerrittest",
-		Ping: func(input *dockertest.PingInput) error {
-			pingStart := time.Now()
-			entry := startLog.WithField("status", "ping")
-			entry.WithField("task", "begin").Debug()
-
-			containerSSH, err := input.Container.Port(ExportedSSHPort)
-			if err != nil {
-				entry.WithError(err).Error()
-				return err
+// demux splits a stream multiplexed by the Docker API into
+// stdout and stderr.
+func demux(reader io.Reader, stdout io.Writer, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return nil
 			}
+			return err
+		}
 
-			containerHTTP, err := input.Container.Port(ExportedHTTPPort)
-			if err != nil {
-				entry.WithError(err).Error()
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		switch header[0] {
+		case 0, 1:
+			if _, err := io.CopyN(stdout, reader, size); err != nil {
 				return err
 			}
-
-			// Wait for ports to open
-			errs := make(chan error, 2)
-			results := errset.ErrSet{}
-			go waitPort(parent, containerSSH, errs)
-			go waitHTTP(parent, containerHTTP, errs)
-			for i := 0; i < 2; i++ {
-				results = append(results, <-errs)
+		case 2:
+			if _, err := io.CopyN(stderr, reader, size); err != nil {
+				return err
 			}
+		default:
+			message := &bytes.Buffer{}
+			if _, err := io.CopyN(message, reader, size); err != nil {
+				return err
+			}
+			return errors.New(message.String())
+		}
+	}
+}
+
+// Exec runs a command inside of the container and returns the output. An
+// error will be returned if the command exits non-zero.
+func (c *Container) Exec(command ...string) ([]byte, []byte, error) {
+	runtime, err := c.runtime()
+	if err != nil {
+		return nil, nil, err
+	}
+	return runtime.Exec(c.getContext(), c.ID, command)
+}
+
+// wait runs the readiness probes against the services in the container
+// and then sets the HTTP and SSH fields.
+func (c *Container) wait(probes []ReadinessProbe) error {
+	logger := log.WithFields(log.Fields{
+		"cmp":    "container",
+		"phase":  "service",
+		"status": "ping",
+	})
+	pingStart := time.Now()
+	logger.WithField("task", "begin").Debug()
+
+	state, err := c.Runtime.Inspect(c.ctx, c.ID)
+	if err != nil {
+		logger.WithError(err).Error()
+		return err
+	}
+	if state.HTTP == nil || state.SSH == nil {
+		logger.WithError(dockertest.ErrPortNotFound).Error()
+		return dockertest.ErrPortNotFound
+	}
+
+	// Wait for ports to open
+	if err := ping(c.ctx, probes, state.HTTP, state.SSH); err != nil {
+		logger.WithError(err).Error()
+		return err
+	}
+	logger.WithFields(log.Fields{
+		"task":    "end",
+		"elapsed": time.Since(pingStart),
+	}).Debug()
+	c.SSH = state.SSH
+	c.HTTP = state.HTTP
+	return nil
+}
+
+// tailLogs returns the last lines of the container's log.
+func (c *Container) tailLogs(ctx context.Context, lines int) ([]string, error) {
+	runtime, err := c.runtime()
+	if err != nil {
+		return []string{}, err
+	}
+	output := &bytes.Buffer{}
+	err = runtime.Logs(ctx, c.ID, output, false, strconv.Itoa(lines))
+	trimmed := strings.TrimRight(output.String(), "\n")
+	if trimmed == "" {
+		return []string{}, err
+	}
+	return strings.Split(trimmed, "\n"), err
+}
+
+// Logs writes the container's console log to writer. If follow is true
+// then this function will continue to write to writer until ctx is
+// cancelled or the container exits.
+func (c *Container) Logs(ctx context.Context, writer io.Writer, follow bool) error {
+	runtime, err := c.runtime()
+	if err != nil {
+		return err
+	}
+	return runtime.Logs(ctx, c.ID, writer, follow, "all")
+}
+
+// reusable returns true if the given container is publishing the
+// requested http and ssh ports. A requested port of dockertest.RandomPort
+// matches any public port.
+func reusable(state *ContainerState, http uint16, ssh uint16) bool {
+	if state.HTTP == nil || state.SSH == nil {
+		return false
+	}
+	if http != dockertest.RandomPort && state.HTTP.Public != http {
+		return false
+	}
+	if ssh != dockertest.RandomPort && state.SSH.Public != ssh {
+		return false
+	}
+	return true
+}
+
+// ping runs the provided probes in parallel against the http and ssh
+// ports and waits for them to finish. If probes is nil DefaultProbes()
+// will be used.
+func ping(ctx context.Context, probes []ReadinessProbe, http *dockertest.Port, ssh *dockertest.Port) error {
+	if probes == nil {
+		probes = DefaultProbes()
+	}
+	errs := make(chan error, len(probes))
+	results := errset.ErrSet{}
+	for _, probe := range probes {
+		go waitProbe(ctx, probe, http, ssh, errs)
+	}
+	for range probes {
+		results = append(results, <-errs)
+	}
+	return results.ReturnValue()
+}
+
+// getRuntime returns runtime or, if runtime is nil, a new *DockerRuntime.
+func getRuntime(runtime Runtime) (Runtime, error) {
+	if runtime != nil {
+		return runtime, nil
+	}
+	return NewDockerRuntime()
+}
+
+// checkImageRevision returns an error if input uses a site or a memory
+// limit and the image of the container id was built before ImageRevision.
+func checkImageRevision(ctx context.Context, runtime Runtime, id string, input *ContainerInput) error {
+	if _, site := input.Labels[LabelSite]; !site && input.Memory <= 0 {
+		return nil
+	}
+	state, err := runtime.Inspect(ctx, id)
+	if err != nil {
+		return err
+	}
+	revision, err := strconv.Atoi(state.Labels[LabelImageRevision])
+	if err != nil || revision < ImageRevision {
+		return fmt.Errorf(
+			"image %s does not support Config.SiteDir or Config.Memory, "+
+				"an image with the %s label set to %d or later is required",
+			input.Image, LabelImageRevision, ImageRevision)
+	}
+	return nil
+}
+
+// newContainer starts a container using the provided input and waits for
+// the services inside of it to come up. If the services fail to come up
+// the container is removed and a *ContainerError containing the last lines
+// of the container's log is returned. The container is also removed if
+// its image is too old for input, see ImageRevision.
+func newContainer(parent context.Context, runtime Runtime, probes []ReadinessProbe, input *ContainerInput) (*Container, error) {
+	logger := log.WithFields(log.Fields{
+		"cmp": "container",
+	})
+	logger.WithField("image", input.Image).Debug()
 
-			entry.WithFields(log.Fields{
-				"task":    "end",
-				"elapsed": time.Since(pingStart),
-			}).Debug()
-			return results.ReturnValue()
-		},
+	runtime, err := getRuntime(runtime)
+	if err != nil {
+		return nil, err
 	}
 
-	// Call run which will start the container and wait for Ping() in
-	// the service above to return.
+	startLog := logger.WithField("phase", "service")
 	start := time.Now()
 	startLog.WithField("task", "begin").Debug()
-	if err := service.Run(); err != nil {
+	id, err := runtime.Start(parent, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkImageRevision(parent, runtime, id, input); err != nil {
 		errs := errset.ErrSet{}
 		errs = append(errs, err)
-		errs = append(errs, service.Terminate())
+		errs = append(errs, runtime.Remove(context.Background(), id))
 		return nil, errs.ReturnValue()
 	}
+	container := &Container{
+		ctx:     parent,
+		Runtime: runtime,
+		Image:   input.Image,
+		ID:      id,
+	}
+
+	if err := container.wait(probes); err != nil {
+		// The parent context may have been cancelled so a new
+		// context is used to retrieve the logs and cleanup.
+		ctx := context.Background()
+		lines, logErr := container.tailLogs(ctx, LogLines)
+		errs := errset.ErrSet{}
+		errs = append(errs, err)
+		errs = append(errs, logErr)
+		errs = append(errs, runtime.Remove(ctx, container.ID))
+		return nil, &ContainerError{
+			Err:  errs.ReturnValue(),
+			ID:   container.ID,
+			Logs: lines,
+		}
+	}
 	startLog.WithFields(log.Fields{
 		"task":    "end",
 		"elapsed": time.Since(start),
 	}).Debug()
+	return container, nil
+}
+
+// NewContainer will create a new container using dockertest and return
+// it. If you prefer to use an existing container use one of the LoadContainer*
+// functions instead. This function will not return until the container has
+// started and is listening on the requested ports.
+func NewContainer(parent context.Context, http uint16, ssh uint16, image string) (*Container, error) {
+	return NewContainerFromConfig(newContainerConfig(parent, http, ssh, image))
+}
 
-	portSSH, err := service.Container.Port(ExportedSSHPort)
+// newContainerConfig returns a *Config for NewContainer
+// and AcquireContainer.
+func newContainerConfig(parent context.Context, http uint16, ssh uint16, image string) *Config {
+	cfg := NewConfig()
+	cfg.Context = parent
+	cfg.PortHTTP = http
+	cfg.PortSSH = ssh
+	cfg.Image = image
+	return cfg
+}
+
+// NewContainerFromConfig is similar to NewContainer except the container
+// is described by cfg, including its ports, image, name, resource limits,
+// Runtime and readiness probes. If cfg.SnapshotImage is set it will be
+// used instead of cfg.Image. Unless cfg.Detached is set, or
+// cfg.CleanupContainer is not, the container is labeled with the current
+// process so GC() can remove it if the process exits without calling
+// Terminate().
+func NewContainerFromConfig(cfg *Config) (*Container, error) {
+	input, err := getDockerClientInput(cfg)
 	if err != nil {
 		return nil, err
 	}
+	if err := input.setOwner(cfg); err != nil {
+		return nil, err
+	}
+	return newContainer(cfg.Context, cfg.Runtime, cfg.Probes, input)
+}
+
+// containerImage returns the image a container should be started
+// from for the given config.
+func containerImage(cfg *Config) string {
+	if cfg.SnapshotImage != "" {
+		return cfg.SnapshotImage
+	}
+	return cfg.Image
+}
 
-	portHTTP, err := service.Container.Port(ExportedHTTPPort)
+// AcquireContainer is similar to NewContainer except it will attempt to
+// attach to a running container that was previously started by
+// AcquireContainer. Containers are located using the LabelReuse label and
+// must be running the requested image, be listening on the requested ports
+// and respond within ReuseTimeout. If no such container exists a new one
+// will be started and labeled so it can be reused later on.
+func AcquireContainer(parent context.Context, http uint16, ssh uint16, image string) (*Container, error) {
+	return AcquireContainerFromConfig(newContainerConfig(parent, http, ssh, image))
+}
+
+// AcquireContainerFromConfig is similar to AcquireContainer except the
+// container is described by cfg. See NewContainerFromConfig.
+func AcquireContainerFromConfig(cfg *Config) (*Container, error) {
+	input, err := getDockerClientInput(cfg)
 	if err != nil {
 		return nil, err
 	}
+	return acquireContainer(
+		cfg.Context, cfg.Runtime, cfg.Probes, cfg.PortHTTP, cfg.PortSSH, input)
+}
 
-	return &Container{
-		ctx:    parent,
-		Docker: client,
-		SSH:    portSSH,
-		HTTP:   portHTTP,
-		Image:  image,
-		ID:     service.Container.ID(),
-	}, nil
+// acquireContainer attaches to a running container with the same image and
+// labels as input which is listening on the requested http and ssh ports.
+// If no such container exists one will be created using input.
+func acquireContainer(parent context.Context, runtime Runtime, probes []ReadinessProbe, http uint16, ssh uint16, input *ContainerInput) (*Container, error) {
+	image := input.Image
+	logger := log.WithFields(log.Fields{
+		"cmp":   "container",
+		"phase": "acquire",
+		"image": image,
+	})
+	input.SetLabel(LabelReuse, "1")
+
+	runtime, err := getRuntime(runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	search := dockertest.NewClientInput(image)
+	for key, value := range input.Labels {
+		search.SetLabel(key, value)
+	}
+	search.Status = "running"
+	logger.WithField("action", "list").Debug()
+	containers, err := runtime.List(parent, search)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, state := range containers {
+		entry := logger.WithField("id", state.ID)
+		if !reusable(state, http, ssh) {
+			entry.WithField("action", "skip-ports").Debug()
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(parent, ReuseTimeout)
+		err := ping(ctx, probes, state.HTTP, state.SSH)
+		cancel()
+		if err != nil {
+			entry.WithError(err).Warn()
+			continue
+		}
+
+		entry.WithField("action", "reuse").Debug()
+		return &Container{
+			ctx:     parent,
+			Runtime: runtime,
+			SSH:     state.SSH,
+			HTTP:    state.HTTP,
+			Image:   image,
+			ID:      state.ID,
+		}, nil
+	}
+
+	logger.WithField("action", "new").Debug()
+	return newContainer(parent, runtime, probes, input)
 }
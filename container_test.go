@@ -1,13 +1,12 @@
 package gerrittest
 
 import (
-	"context"
-	"net"
-	"net/http"
-	"net/http/httptest"
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
-	"strings"
 
 	"github.com/opalmer/dockertest"
 	. "gopkg.in/check.v1"
@@ -74,54 +73,142 @@ func (s *ContainerTest) TestGetDockerImage_DirectInput(c *C) {
 	c.Assert(GetDockerImage("hello"), Equals, "hello")
 }
 
-func (s *ContainerTest) Test_waitPort_contextCancelled(c *C) {
-	ctx, cancel := context.WithCancel(context.Background())
-	cancel()
-	errs := make(chan error, 1)
-	waitPort(ctx, &dockertest.Port{Private: 0, Public: 0, Address: ""}, errs)
-	c.Assert(<-errs, ErrorMatches, context.Canceled.Error())
+func (s *ContainerTest) Test_reusable(c *C) {
+	state := &ContainerState{
+		HTTP: &dockertest.Port{Private: ExportedHTTPPort, Public: 1},
+		SSH:  &dockertest.Port{Private: ExportedSSHPort, Public: 2},
+	}
+	c.Assert(reusable(state, dockertest.RandomPort, dockertest.RandomPort), Equals, true)
+	c.Assert(reusable(state, 1, 2), Equals, true)
+	c.Assert(reusable(state, 3, dockertest.RandomPort), Equals, false)
+	c.Assert(reusable(state, dockertest.RandomPort, 3), Equals, false)
+}
+
+func (s *ContainerTest) Test_reusable_missingPorts(c *C) {
+	c.Assert(reusable(&ContainerState{}, dockertest.RandomPort, dockertest.RandomPort), Equals, false)
+}
+
+func (s *ContainerTest) frame(stream byte, content string) []byte {
+	header := []byte{stream, 0, 0, 0, 0, 0, 0, byte(len(content))}
+	return append(header, []byte(content)...)
+}
+
+func (s *ContainerTest) Test_demux(c *C) {
+	input := &bytes.Buffer{}
+	input.Write(s.frame(1, "out1"))
+	input.Write(s.frame(2, "err1"))
+	input.Write(s.frame(1, "out2"))
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	c.Assert(demux(input, stdout, stderr), IsNil)
+	c.Assert(stdout.String(), Equals, "out1out2")
+	c.Assert(stderr.String(), Equals, "err1")
+}
+
+func (s *ContainerTest) Test_demux_systemError(c *C) {
+	input := bytes.NewBuffer(s.frame(3, "failed"))
+	c.Assert(demux(input, &bytes.Buffer{}, &bytes.Buffer{}), ErrorMatches, "failed")
+}
+
+func (s *ContainerTest) Test_demux_truncated(c *C) {
+	input := bytes.NewBuffer([]byte{1, 0, 0})
+	c.Assert(demux(input, &bytes.Buffer{}, &bytes.Buffer{}), NotNil)
+}
+
+func (s *ContainerTest) TestContainerError(c *C) {
+	err := &ContainerError{
+		Err:  errors.New("failed"),
+		ID:   "abc",
+		Logs: []string{"line 1", "line 2"},
+	}
+	c.Assert(
+		err.Error(), Equals,
+		"failed\n--- last 2 lines of log for container abc ---\nline 1\nline 2")
 }
 
-func (s *ContainerTest) Test_waitPort(c *C) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
+func (s *ContainerTest) TestContainerInput_bindSite(c *C) {
+	dir := c.MkDir()
+	input, err := getDockerClientInput(NewConfig())
 	c.Assert(err, IsNil)
-	defer listener.Close() // nolint: errcheck
-	port, err := strconv.ParseUint(
-		strings.Split(listener.Addr().String(), ":")[1], 10, 16)
+	c.Assert(input.bindSite(dir), IsNil)
+	c.Assert(input.Binds, HasLen, len(SiteMounts))
+	for i, name := range SiteMounts {
+		c.Assert(input.Binds[i], Equals, fmt.Sprintf(
+			"%s:%s/%s", filepath.Join(dir, name), SitePath, name))
+		info, err := os.Stat(filepath.Join(dir, name))
+		c.Assert(err, IsNil)
+		c.Assert(info.IsDir(), Equals, true)
+	}
+	c.Assert(input.Labels[LabelSite], Equals, dir)
+}
+
+func (s *ContainerTest) Test_getDockerClientInput(c *C) {
+	cfg := NewConfig()
+	cfg.Image = "image"
+	cfg.PortHTTP = 1
+	cfg.PortSSH = 2
+	cfg.ContainerName = "gerrit"
+	cfg.ContainerLabels["team"] = "ci"
+	cfg.Environment["B"] = "2"
+	cfg.Environment["A"] = "1"
+	cfg.Memory = 1024 * 1024 * 1024
+	cfg.CPUs = 1.5
+	cfg.Network = "ci"
+	input, err := getDockerClientInput(cfg)
 	c.Assert(err, IsNil)
-	errs := make(chan error, 1)
-	waitPort(ctx, &dockertest.Port{Private: 0, Public: uint16(port), Address: "127.0.0.1"}, errs)
-	c.Assert(<-errs, IsNil)
-}
-
-func (s *ContainerTest) Test_waitHTTP_contextCancelled(c *C) {
-	ctx, cancel := context.WithCancel(context.Background())
-	cancel()
-	errs := make(chan error, 1)
-	waitHTTP(ctx, &dockertest.Port{Private: 0, Public: 0, Address: ""}, errs)
-	c.Assert(<-errs, ErrorMatches, context.Canceled.Error())
-}
-
-func (s *ContainerTest) Test_waitHTTP(c *C) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	count := 0
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		count++
-		if count != 2 {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer ts.Close()
-	port, err := strconv.ParseUint(
-		strings.Split(ts.Listener.Addr().String(), ":")[1], 10, 16)
+	c.Assert(input.Image, Equals, "image")
+	c.Assert(input.Name, Equals, "gerrit")
+	c.Assert(input.Labels["team"], Equals, "ci")
+	c.Assert(input.Memory, Equals, int64(1024*1024*1024))
+	c.Assert(input.NanoCPUs, Equals, int64(1500000000))
+	c.Assert(input.Network, Equals, "ci")
+	c.Assert(input.Binds, HasLen, 0)
+	c.Assert(input.Environment, DeepEquals, []string{
+		"A=1", "B=2", "GERRIT_HEAP_LIMIT=768m",
+		"GERRIT_CANONICAL_URL=http://127.0.0.1:1/"})
+}
+
+func (s *ContainerTest) Test_getDockerClientInput_heapLimitSet(c *C) {
+	cfg := NewConfig()
+	cfg.Memory = 1024 * 1024 * 1024
+	cfg.Environment["GERRIT_HEAP_LIMIT"] = "512m"
+	input, err := getDockerClientInput(cfg)
 	c.Assert(err, IsNil)
+	c.Assert(input.Environment[0], Equals, "GERRIT_HEAP_LIMIT=512m")
+	c.Assert(input.Environment, HasLen, 2)
+}
 
-	errs := make(chan error, 1)
-	waitHTTP(ctx, &dockertest.Port{Private: 0, Public: uint16(port), Address: "127.0.0.1"}, errs)
-	c.Assert(<-errs, IsNil)
+func (s *ContainerTest) Test_getDockerClientInput_snapshotImage(c *C) {
+	cfg := NewConfig()
+	cfg.SnapshotImage = "snapshot"
+	input, err := getDockerClientInput(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(input.Image, Equals, "snapshot")
+}
+
+func (s *ContainerTest) TestContainerInput_setOwner(c *C) {
+	cfg := NewConfig()
+	cfg.SSHKeys = []*SSHKey{{Path: "/tmp/a"}, {Path: "/tmp/b"}}
+	input, err := getDockerClientInput(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(input.setOwner(cfg), IsNil)
+	c.Assert(input.Labels[LabelPID], Equals, strconv.Itoa(os.Getpid()))
+	c.Assert(input.Labels[LabelCreated], Not(Equals), "")
+	c.Assert(input.Labels[LabelSSHKeys], Equals, `["/tmp/a","/tmp/b"]`)
+}
+
+func (s *ContainerTest) TestContainerInput_setOwner_notOwned(c *C) {
+	detached := NewConfig()
+	detached.Detached = true
+	kept := NewConfig()
+	kept.CleanupContainer = false
+	for _, cfg := range []*Config{detached, kept} {
+		input, err := getDockerClientInput(cfg)
+		c.Assert(err, IsNil)
+		c.Assert(input.setOwner(cfg), IsNil)
+		_, set := input.Labels[LabelPID]
+		c.Assert(set, Equals, false)
+		c.Assert(input.Labels[LabelCreated], Not(Equals), "")
+		c.Assert(input.Labels[LabelSSHKeys], Equals, "[]")
+	}
 }
@@ -4,5 +4,17 @@ set_gerrit_config() {
   git config -f "${GERRIT_SITE}/etc/gerrit.config" "$@"
 }
 
+# The git, db and index directories may be bind mounted from the host
+# by gerrittest. If they're empty the site needs to be initialized before
+# Gerrit can start.
+if [ ! -d "${GERRIT_SITE}/git/All-Projects.git" ]; then
+  java -jar ${GERRIT_SITE}/bin/gerrit.war init --batch --no-auto-start -d ${GERRIT_SITE}
+elif [ -z "$(ls -A ${GERRIT_SITE}/index)" ]; then
+  java -jar ${GERRIT_SITE}/bin/gerrit.war reindex -d ${GERRIT_SITE}
+fi
+
 set_gerrit_config gerrit.canonicalWebUrl ${GERRIT_CANONICAL_URL}
-exec java -jar ${GERRIT_SITE}/bin/gerrit.war daemon --console-log -d ${GERRIT_SITE}
+
+# GERRIT_HEAP_LIMIT is set by gerrittest when the container's memory is
+# limited so the JVM's heap fits inside of the container.
+exec java ${GERRIT_HEAP_LIMIT:+-Xmx${GERRIT_HEAP_LIMIT}} -jar ${GERRIT_SITE}/bin/gerrit.war daemon --console-log -d ${GERRIT_SITE}
@@ -39,7 +39,11 @@ func ExampleGerrit_CreateChange() {
 		"scripts/foo.bash": "echo 'foo'",
 	}
 
-	change, err := gerrit.CreateChange("testing", "test")
+	project, err := gerrit.CreateProject("testing", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	change, err := gerrit.CreateChange(project, "master", "test")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -65,3 +69,86 @@ func ExampleGerrit_CreateChange() {
 	}
 
 }
+
+// Tests which exercise review workflows usually need more than the
+// administrative user. CreateUser creates an account with its own ssh key,
+// http password and clients so changes can be pushed by one user and
+// reviewed by another.
+func ExampleGerrit_CreateUser() {
+	gerrit, err := New(NewConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer gerrit.Destroy() // nolint: errcheck
+
+	author, err := gerrit.CreateUser("author", "author@localhost", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer author.Destroy() // nolint: errcheck
+
+	repo, err := author.NewRepository()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer repo.Destroy() // nolint: errcheck
+	if err := repo.AddOriginFromContainer(gerrit.Container, ProjectName); err != nil {
+		log.Fatal(err)
+	}
+	if err := repo.Commit("Add feature"); err != nil {
+		log.Fatal(err)
+	}
+	if err := repo.Push("HEAD:refs/for/master"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Groups can be referred to by name in access rules. In this example only
+// members of the CI group may vote on the Verified label.
+func ExampleGerrit_Grant() {
+	gerrit, err := New(NewConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer gerrit.Destroy() // nolint: errcheck
+
+	bot, err := gerrit.CreateUser("ci-bot", "ci-bot@localhost", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer bot.Destroy() // nolint: errcheck
+
+	if _, err := gerrit.CreateGroup("CI", &GroupOptions{Members: []string{bot.Username}}); err != nil {
+		log.Fatal(err)
+	}
+	if err := gerrit.Grant("All-Projects", &AccessRule{
+		Ref:        "refs/heads/*",
+		Permission: "label-Verified",
+		Range:      "-1..+1",
+		Group:      "CI",
+	}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewProjectConfig builds changes to a project's project.config which are
+// applied on top of the existing configuration when pushed.
+func ExampleGerrit_PushProjectConfig() {
+	gerrit, err := New(NewConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer gerrit.Destroy() // nolint: errcheck
+
+	cfg := NewProjectConfig().
+		SetSubmitType(SubmitTypeFastForwardOnly).
+		SetReceiveOption("requireSignedOffBy", "true").
+		Grant(&AccessRule{
+			Ref:        "refs/heads/*",
+			Permission: "submit",
+			Group:      "Administrators",
+		})
+	if err := gerrit.PushProjectConfig("All-Projects", cfg); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,227 @@
+package gerrittest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/crewjam/errset"
+	"github.com/opalmer/dockertest"
+	log "github.com/sirupsen/logrus"
+)
+
+// tempNameRegex matches the names of temporary files and directories
+// created by tempPrefix(). Older versions of gerrittest did not include
+// the process id in the name so it's optional.
+var tempNameRegex = regexp.MustCompile(
+	fmt.Sprintf(`^%s-(?:id_rsa-)?(?:(\d+)-)?\d+$`, ProjectName))
+
+// tempPrefix returns the prefix to use for temporary files and
+// directories. The prefix includes the current process id so GC()
+// can locate files left behind by processes which have exited.
+func tempPrefix(kind string) string {
+	if kind == "" {
+		return fmt.Sprintf("%s-%d-", ProjectName, os.Getpid())
+	}
+	return fmt.Sprintf("%s-%s-%d-", ProjectName, kind, os.Getpid())
+}
+
+// processRunning returns true if the process with the given id
+// is running on this machine.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// GCConfig is used to configure GC().
+type GCConfig struct {
+	// Context is used when listing and removing containers.
+	Context context.Context
+
+	// Runtime is used to locate and remove containers. If no runtime
+	// is provided then Docker will be used.
+	Runtime Runtime
+
+	// TempDir is the directory to search for temporary files and
+	// directories. Defaults to os.TempDir().
+	TempDir string
+
+	// MaxAge, when non-zero, causes containers and temporary files
+	// older than MaxAge to be removed even if the process that created
+	// them is still running or can't be determined.
+	MaxAge time.Duration
+
+	// DryRun when true will cause GC() to report what it would
+	// remove without removing anything.
+	DryRun bool
+}
+
+// GCResult is returned by GC() and describes what was removed.
+type GCResult struct {
+	// Containers contains the ids of the containers removed.
+	Containers []string `json:"containers"`
+
+	// Paths contains the temporary files and directories removed.
+	Paths []string `json:"paths"`
+}
+
+// stale returns true if an item created by pid at the given time should
+// be removed. known is false if the process which created the item can't
+// be checked, for example because it ran on another machine.
+func (c *GCConfig) stale(pid int, known bool, created time.Time) bool {
+	if known && !processRunning(pid) {
+		return true
+	}
+	return c.MaxAge > 0 && !created.IsZero() && time.Since(created) > c.MaxAge
+}
+
+// staleContainers returns the containers started by NewContainer which
+// should be removed along with the paths to the ssh keys still referenced
+// by the containers which should be kept.
+func (c *GCConfig) staleContainers(runtime Runtime) ([]*ContainerState, map[string]bool, error) {
+	input := dockertest.NewClientInput("")
+	input.All = true
+	containers, err := runtime.List(c.Context, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostname, _ := os.Hostname() // nolint: errcheck
+	stale := []*ContainerState{}
+	keys := map[string]bool{}
+	for _, state := range containers {
+		value, owned := state.Labels[LabelPID]
+		createdValue, set := state.Labels[LabelCreated]
+		if owned || set {
+			// Containers without LabelPID are expected to outlive the
+			// process which created them so only MaxAge applies.
+			pid, err := strconv.Atoi(value)
+			known := owned && err == nil && state.Labels[LabelHost] == hostname
+			created, err := time.Parse(time.RFC3339, createdValue)
+			if err != nil {
+				created = state.Created
+			}
+			if c.stale(pid, known, created) {
+				stale = append(stale, state)
+				continue
+			}
+		}
+
+		paths := []string{}
+		if value, set := state.Labels[LabelSSHKeys]; set {
+			if err := json.Unmarshal([]byte(value), &paths); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, path := range paths {
+			keys[path] = true
+		}
+	}
+	return stale, keys, nil
+}
+
+// stalePaths returns the temporary files and directories in TempDir
+// which should be removed. Paths in keep are never returned.
+func (c *GCConfig) stalePaths(keep map[string]bool) ([]string, error) {
+	infos, err := ioutil.ReadDir(c.TempDir)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, info := range infos {
+		match := tempNameRegex.FindStringSubmatch(info.Name())
+		if match == nil {
+			continue
+		}
+		path := filepath.Join(c.TempDir, info.Name())
+		if keep[path] {
+			continue
+		}
+		pid, err := strconv.Atoi(match[1])
+		if c.stale(pid, err == nil, info.ModTime()) {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// GC removes containers started by NewContainer and temporary files
+// created by NewSSHKey and NewRepository which were left behind by
+// processes that exited without cleaning up, for example because a test
+// binary was killed. Containers started by AcquireContainer are never
+// removed because they're intended to outlive the process. Detached
+// containers, and those which are not cleaned up, are only removed once
+// they're older than MaxAge. Temporary ssh keys used by containers which
+// are not removed are kept.
+func GC(cfg *GCConfig) (*GCResult, error) {
+	logger := log.WithFields(log.Fields{
+		"cmp":     "gc",
+		"dry-run": cfg.DryRun,
+	})
+	runtime, err := getRuntime(cfg.Runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GCResult{Containers: []string{}, Paths: []string{}}
+	containers, keys, err := cfg.staleContainers(runtime)
+	if err != nil {
+		return nil, err
+	}
+	errs := errset.ErrSet{}
+	for _, state := range containers {
+		logger.WithFields(log.Fields{
+			"phase": "remove-container",
+			"id":    state.ID,
+		}).Debug()
+		if !cfg.DryRun {
+			if err := runtime.Remove(cfg.Context, state.ID); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		result.Containers = append(result.Containers, state.ID)
+	}
+
+	paths, err := cfg.stalePaths(keys)
+	if err != nil {
+		errs = append(errs, err)
+		return result, errs.ReturnValue()
+	}
+	for _, path := range paths {
+		logger.WithFields(log.Fields{
+			"phase": "remove-path",
+			"path":  path,
+		}).Debug()
+		if !cfg.DryRun {
+			if err := os.RemoveAll(path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		result.Paths = append(result.Paths, path)
+	}
+	return result, errs.ReturnValue()
+}
+
+// NewGCConfig returns a *GCConfig with the default settings.
+func NewGCConfig() *GCConfig {
+	return &GCConfig{
+		Context: context.Background(),
+		TempDir: os.TempDir(),
+	}
+}
@@ -0,0 +1,198 @@
+package gerrittest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/opalmer/dockertest"
+	. "gopkg.in/check.v1"
+)
+
+type GCTest struct {
+	hostname string
+	deadPID  string
+}
+
+var _ = Suite(&GCTest{})
+
+func (s *GCTest) SetUpSuite(c *C) {
+	hostname, err := os.Hostname()
+	c.Assert(err, IsNil)
+	s.hostname = hostname
+
+	// Use the id of a process which has already exited.
+	command := exec.Command("true")
+	c.Assert(command.Run(), IsNil)
+	s.deadPID = strconv.Itoa(command.Process.Pid)
+}
+
+func (s *GCTest) start(c *C, runtime Runtime, labels map[string]string) string {
+	input := &ContainerInput{ClientInput: dockertest.NewClientInput("")}
+	for key, value := range labels {
+		input.SetLabel(key, value)
+	}
+	id, err := runtime.Start(context.Background(), input)
+	c.Assert(err, IsNil)
+	return id
+}
+
+func (s *GCTest) config(c *C, runtime Runtime) *GCConfig {
+	cfg := NewGCConfig()
+	cfg.Runtime = runtime
+	cfg.TempDir = c.MkDir()
+	return cfg
+}
+
+func (s *GCTest) Test_tempPrefix(c *C) {
+	for _, kind := range []string{"", "id_rsa"} {
+		match := tempNameRegex.FindStringSubmatch(tempPrefix(kind) + "12345")
+		c.Assert(match, NotNil)
+		c.Assert(match[1], Equals, strconv.Itoa(os.Getpid()))
+	}
+}
+
+func (s *GCTest) Test_processRunning(c *C) {
+	c.Assert(processRunning(os.Getpid()), Equals, true)
+	pid, err := strconv.Atoi(s.deadPID)
+	c.Assert(err, IsNil)
+	c.Assert(processRunning(pid), Equals, false)
+	c.Assert(processRunning(0), Equals, false)
+}
+
+func (s *GCTest) TestGC_containers(c *C) {
+	runtime := NewFakeRuntime(nil, nil)
+	stale := s.start(c, runtime, map[string]string{
+		LabelPID: s.deadPID, LabelHost: s.hostname})
+	running := s.start(c, runtime, map[string]string{
+		LabelPID: strconv.Itoa(os.Getpid()), LabelHost: s.hostname})
+	otherHost := s.start(c, runtime, map[string]string{
+		LabelPID: s.deadPID, LabelHost: s.hostname + "-other"})
+	reused := s.start(c, runtime, map[string]string{LabelReuse: "1"})
+	detached := s.start(c, runtime, map[string]string{
+		LabelHost:    s.hostname,
+		LabelCreated: time.Now().Add(-time.Hour).Format(time.RFC3339)})
+
+	result, err := GC(s.config(c, runtime))
+	c.Assert(err, IsNil)
+	c.Assert(result.Containers, DeepEquals, []string{stale})
+	for _, id := range []string{running, otherHost, reused, detached} {
+		_, err := runtime.Inspect(context.Background(), id)
+		c.Assert(err, IsNil)
+	}
+	_, err = runtime.Inspect(context.Background(), stale)
+	c.Assert(err, Equals, ErrContainerNotFound)
+}
+
+func (s *GCTest) TestGC_containersMaxAge(c *C) {
+	runtime := NewFakeRuntime(nil, nil)
+	old := s.start(c, runtime, map[string]string{
+		LabelPID:     strconv.Itoa(os.Getpid()),
+		LabelHost:    s.hostname + "-other",
+		LabelCreated: time.Now().Add(-time.Hour).Format(time.RFC3339)})
+	s.start(c, runtime, map[string]string{
+		LabelPID:     strconv.Itoa(os.Getpid()),
+		LabelHost:    s.hostname,
+		LabelCreated: time.Now().Format(time.RFC3339)})
+	detached := s.start(c, runtime, map[string]string{
+		LabelHost:    s.hostname,
+		LabelCreated: time.Now().Add(-time.Hour).Format(time.RFC3339)})
+	s.start(c, runtime, map[string]string{
+		LabelHost:    s.hostname,
+		LabelCreated: time.Now().Format(time.RFC3339)})
+
+	cfg := s.config(c, runtime)
+	cfg.MaxAge = time.Minute
+	result, err := GC(cfg)
+	c.Assert(err, IsNil)
+	expected := []string{old, detached}
+	sort.Strings(expected)
+	sort.Strings(result.Containers)
+	c.Assert(result.Containers, DeepEquals, expected)
+}
+
+func (s *GCTest) TestGC_keysInUse(c *C) {
+	runtime := NewFakeRuntime(nil, nil)
+	cfg := s.config(c, runtime)
+	write := func(name string) string {
+		path := filepath.Join(cfg.TempDir, name)
+		c.Assert(ioutil.WriteFile(path, []byte(""), 0600), IsNil)
+		return path
+	}
+	used := write(ProjectName + "-id_rsa-" + s.deadPID + "-1")
+	unused := write(ProjectName + "-id_rsa-" + s.deadPID + "-2")
+	staleKey := write(ProjectName + "-id_rsa-" + s.deadPID + "-3")
+	s.start(c, runtime, map[string]string{
+		LabelHost:    s.hostname,
+		LabelCreated: time.Now().Format(time.RFC3339),
+		LabelSSHKeys: `["` + used + `"]`})
+	stale := s.start(c, runtime, map[string]string{
+		LabelPID:     s.deadPID,
+		LabelHost:    s.hostname,
+		LabelSSHKeys: `["` + staleKey + `"]`})
+
+	result, err := GC(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(result.Containers, DeepEquals, []string{stale})
+	expected := []string{unused, staleKey}
+	sort.Strings(expected)
+	c.Assert(result.Paths, DeepEquals, expected)
+	_, err = os.Stat(used)
+	c.Assert(err, IsNil)
+}
+
+func (s *GCTest) TestGC_paths(c *C) {
+	runtime := NewFakeRuntime(nil, nil)
+	cfg := s.config(c, runtime)
+	cfg.MaxAge = time.Minute
+	write := func(name string, modified time.Time) string {
+		path := filepath.Join(cfg.TempDir, name)
+		c.Assert(ioutil.WriteFile(path, []byte(""), 0600), IsNil)
+		c.Assert(os.Chtimes(path, modified, modified), IsNil)
+		return path
+	}
+	now := time.Now()
+	dead := write(ProjectName+"-"+s.deadPID+"-1", now)
+	deadKey := write(ProjectName+"-id_rsa-"+s.deadPID+"-2", now)
+	legacy := write(ProjectName+"-3", now.Add(-time.Hour))
+	write(tempPrefix("")+"4", now)
+	write(ProjectName+"-5", now)
+	write("other-"+s.deadPID+"-6", now)
+
+	result, err := GC(cfg)
+	c.Assert(err, IsNil)
+	expected := []string{dead, deadKey, legacy}
+	sort.Strings(expected)
+	c.Assert(result.Paths, DeepEquals, expected)
+	for _, path := range result.Paths {
+		_, err := os.Stat(path)
+		c.Assert(os.IsNotExist(err), Equals, true)
+	}
+	infos, err := ioutil.ReadDir(cfg.TempDir)
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 3)
+}
+
+func (s *GCTest) TestGC_dryRun(c *C) {
+	runtime := NewFakeRuntime(nil, nil)
+	id := s.start(c, runtime, map[string]string{
+		LabelPID: s.deadPID, LabelHost: s.hostname})
+	cfg := s.config(c, runtime)
+	cfg.DryRun = true
+	path := filepath.Join(cfg.TempDir, ProjectName+"-"+s.deadPID+"-1")
+	c.Assert(os.Mkdir(path, 0700), IsNil)
+
+	result, err := GC(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(result.Containers, DeepEquals, []string{id})
+	c.Assert(result.Paths, DeepEquals, []string{path})
+	_, err = runtime.Inspect(context.Background(), id)
+	c.Assert(err, IsNil)
+	_, err = os.Stat(path)
+	c.Assert(err, IsNil)
+}
@@ -3,11 +3,12 @@ package gerrittest
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/crewjam/errset"
 	"github.com/opalmer/dockertest"
@@ -18,6 +19,22 @@ import (
 // field values, etc.
 const ProjectName = "gerrittest"
 
+var (
+	// ErrNotSnapshot is returned when Config.SnapshotImage refers to an
+	// image which was not produced by Gerrit.Snapshot().
+	ErrNotSnapshot = errors.New("image was not produced by Snapshot()")
+)
+
+const (
+	// SiteStateFile is the name of the file in Config.SiteDir which
+	// stores the json produced by WriteJSONFile() once setup completes.
+	SiteStateFile = "gerrittest.json"
+
+	// SiteSSHKey is the name of the private key generated in
+	// Config.SiteDir when no ssh keys are provided.
+	SiteSSHKey = "id_rsa"
+)
+
 // Gerrit is the central struct which combines multiple components
 // of the gerrittest project. Use New() to construct this struct.
 type Gerrit struct {
@@ -30,6 +47,22 @@ type Gerrit struct {
 	HTTPPort  *dockertest.Port `json:"http"`
 	SSH       *SSHClient       `json:"-"`
 	SSHPort   *dockertest.Port `json:"ssh"`
+
+	// ConfigRevision is the revision of refs/meta/config in All-Projects
+	// produced by the setup steps. Reset() restores this revision.
+	ConfigRevision string `json:"config_revision"`
+
+	// reuse is set by Acquire() and causes startContainer to attach to
+	// an existing container if possible.
+	reuse bool
+
+	// restored is true if the state of a previous setup was loaded
+	// from a snapshot or site directory.
+	restored bool
+
+	// release is called by Destroy() once the instance has been
+	// destroyed. This is set by *Pool so the instance can be replaced.
+	release func()
 }
 
 func (g *Gerrit) errLog(logger *log.Entry, err error) error {
@@ -44,8 +77,15 @@ func (g *Gerrit) startContainer() error {
 		"task":  "start-container",
 	})
 	logger.Debug()
-	container, err := NewContainer(
-		g.ctx, g.Config.PortHTTP, g.Config.PortSSH, g.Config.Image)
+	start := NewContainerFromConfig
+	if g.reuse {
+		start = AcquireContainerFromConfig
+	}
+	// The container should use our context so it's cancelled
+	// by Destroy().
+	cfg := *g.Config
+	cfg.Context = g.ctx
+	container, err := start(&cfg)
 	if err != nil {
 		logger.WithError(err).Error()
 		return err
@@ -65,6 +105,89 @@ func (g *Gerrit) startContainer() error {
 	return nil
 }
 
+// restoreState restores the username, password, ssh keys and git
+// configuration from json produced by WriteJSONFile() or Snapshot().
+func (g *Gerrit) restoreState(data []byte) error {
+	state := &Gerrit{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return err
+	}
+	if state.Config == nil {
+		return ErrNotSnapshot
+	}
+	for _, key := range state.Config.SSHKeys {
+		// The key is required to access any container using the
+		// snapshot or site so it should never be removed.
+		key.Generated = false
+		if err := key.load(); err != nil {
+			return err
+		}
+	}
+
+	g.ConfigRevision = state.ConfigRevision
+	g.Config.Username = state.Config.Username
+	g.Config.Password = state.Config.Password
+	g.Config.SSHKeys = state.Config.SSHKeys
+	for key, value := range state.Config.GitConfig {
+		g.Config.GitConfig[key] = value
+	}
+	return nil
+}
+
+// loadSnapshot loads the state stored on Config.SnapshotImage.
+func (g *Gerrit) loadSnapshot() error {
+	logger := g.log.WithFields(log.Fields{
+		"phase": "setup",
+		"task":  "load-snapshot",
+		"image": g.Config.SnapshotImage,
+	})
+	logger.Debug()
+
+	runtime, err := getRuntime(g.Config.Runtime)
+	if err != nil {
+		return g.errLog(logger, err)
+	}
+	labels, err := runtime.ImageLabels(g.ctx, g.Config.SnapshotImage)
+	if err != nil {
+		return g.errLog(logger, err)
+	}
+	value, set := labels[LabelState]
+	if !set {
+		return g.errLog(logger, ErrNotSnapshot)
+	}
+	if err := g.restoreState([]byte(value)); err != nil {
+		return g.errLog(logger, err)
+	}
+	g.restored = true
+	return nil
+}
+
+// loadSite loads the state stored in Config.SiteDir by a previous
+// call to setup(). Nothing is loaded if the site has not been setup.
+func (g *Gerrit) loadSite() error {
+	path := filepath.Join(g.Config.SiteDir, SiteStateFile)
+	logger := g.log.WithFields(log.Fields{
+		"phase": "setup",
+		"task":  "load-site",
+		"path":  path,
+	})
+	logger.Debug()
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		logger.WithField("action", "new-site").Debug()
+		return os.MkdirAll(g.Config.SiteDir, 0700)
+	}
+	if err != nil {
+		return g.errLog(logger, err)
+	}
+	if err := g.restoreState(data); err != nil {
+		return g.errLog(logger, err)
+	}
+	g.restored = true
+	return nil
+}
+
 // setupSSHKey loads or generates an SSH key.
 func (g *Gerrit) setupSSHKey() error {
 	logger := g.log.WithFields(log.Fields{
@@ -74,9 +197,21 @@ func (g *Gerrit) setupSSHKey() error {
 	logger.Debug()
 
 	// If no keys have been provided generate one and add it
-	// to the config.
+	// to the config. Keys for a site are stored in the site
+	// so they remain valid between runs. The key may already
+	// exist if a previous run failed before saving its state.
 	if len(g.Config.SSHKeys) == 0 {
-		key, err := NewSSHKey()
+		create := NewSSHKey
+		if g.Config.SiteDir != "" {
+			create = func() (*SSHKey, error) {
+				path := filepath.Join(g.Config.SiteDir, SiteSSHKey)
+				if _, err := os.Stat(path); err == nil {
+					return LoadSSHKey(path)
+				}
+				return CreateSSHKey(path)
+			}
+		}
+		key, err := create()
 		if err != nil {
 			return err
 		}
@@ -85,8 +220,7 @@ func (g *Gerrit) setupSSHKey() error {
 
 	for _, key := range g.Config.SSHKeys {
 		if key.Default {
-			g.Config.GitConfig["core.sshCommand"] = fmt.Sprintf(
-				"ssh -i %s -o UserKnownHostsFile=/dev/null -o StrictHostKeyChecking=no", key.Path)
+			g.Config.GitConfig["core.sshCommand"] = gitSSHCommand(key)
 			break
 		}
 	}
@@ -94,6 +228,12 @@ func (g *Gerrit) setupSSHKey() error {
 	return nil
 }
 
+// gitSSHCommand returns the value of core.sshCommand for key.
+func gitSSHCommand(key *SSHKey) string {
+	return fmt.Sprintf(
+		"ssh -i %s -o UserKnownHostsFile=/dev/null -o StrictHostKeyChecking=no", key.Path)
+}
+
 func (g *Gerrit) setupHTTPClient() error { // nolint: gocyclo
 	logger := g.log.WithFields(log.Fields{
 		"phase": "setup",
@@ -157,53 +297,59 @@ func (g *Gerrit) setupSSHClient() error {
 	return nil
 }
 
-// pushConfig pushes configuration data to the Gerrit instance. This ensures
-// that certain settings, such as permissions around the Verified +1 tag, are
-// set properly.
-func (g *Gerrit) pushConfig() error { // nolint: gocyclo
+// checkoutConfig creates a new repository with refs/meta/config for the
+// requested project checked out. The caller is responsible for destroying
+// the repository.
+func (g *Gerrit) checkoutConfig(project string) (*Repository, error) {
 	logger := g.log.WithFields(log.Fields{
-		"phase": "setup",
-		"task":  "push-config",
+		"phase":   "checkout-config",
+		"project": project,
 	})
-	logger.Debug()
 
 	logger.WithField("action", "new-repo").Debug()
 	repo, err := NewRepository(g.Config)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer repo.Destroy() // nolint: errcheck
 
-	if err := repo.AddOriginFromContainer(g.Container, "All-Projects"); err != nil {
-		return err
+	if err := repo.AddOriginFromContainer(g.Container, project); err != nil {
+		repo.Destroy() // nolint: errcheck
+		return nil, err
 	}
 
 	if _, _, err := repo.Git([]string{
 		"fetch", "origin", "refs/meta/config:refs/remotes/origin/meta/config"}); err != nil {
-		return err
+		repo.Destroy() // nolint: errcheck
+		return nil, err
 	}
 
 	logger.WithField("action", "checkout").Debug()
 	if _, _, err := repo.Git([]string{"checkout", "meta/config"}); err != nil {
-		return err
+		repo.Destroy() // nolint: errcheck
+		return nil, err
 	}
+	return repo, nil
+}
+
+// pushConfigChanges commits and pushes any changes made to a repository
+// produced by checkoutConfig(). Nothing will be pushed if there are no
+// changes.
+func (g *Gerrit) pushConfigChanges(repo *Repository, message string) error {
+	logger := g.log.WithField("phase", "push-config-changes")
 
-	path := filepath.Join(repo.Root, "project.config")
-	ini, err := newProjectConfig(path)
+	// The config may have already been pushed, such as when we're attached
+	// to a container which was setup previously.
+	status, err := repo.Status()
 	if err != nil {
 		return err
 	}
-	if err := ini.write(path); err != nil {
-		return err
-	}
-
-	logger.WithField("action", "add").Debug()
-	if _, _, err := repo.Git(append(DefaultGitCommands["add"], path)); err != nil {
-		return err
+	if status == "" {
+		logger.WithField("action", "unchanged").Debug()
+		return nil
 	}
 
 	logger.WithField("action", "commit").Debug()
-	if _, _, err := repo.Git([]string{"commit", "--message", "add verified label"}); err != nil {
+	if _, _, err := repo.Git([]string{"commit", "--message", message}); err != nil {
 		return err
 	}
 
@@ -212,43 +358,70 @@ func (g *Gerrit) pushConfig() error { // nolint: gocyclo
 	return err
 }
 
-// CreateChange will return a *Change struct. If a change has already been
-// created then that change will be returned instead of creating a new one.
-func (g *Gerrit) CreateChange(project string, subject string) (*Change, error) { // nolint: gocyclo
-	logger := g.log.WithField("phase", "create-change")
-	client, err := g.HTTP.Gerrit()
+// pushConfig pushes configuration data to the Gerrit instance. This ensures
+// that certain settings, such as the labels in Config.Labels and the
+// permissions to vote on them, are set properly.
+func (g *Gerrit) pushConfig() error {
+	g.log.WithFields(log.Fields{
+		"phase": "setup",
+		"task":  "push-config",
+	}).Debug()
+
+	labels := g.Config.Labels
+	if labels == nil {
+		labels = DefaultLabels()
+	}
+	repo, err := g.updateProjectConfig(
+		"All-Projects", setupProjectConfig(labels), "add labels")
 	if err != nil {
-		logger.WithError(err).Error()
-		return nil, err
+		return err
 	}
+	defer repo.Destroy() // nolint: errcheck
+	return g.setConfigRevision(repo)
+}
 
-	if project == "" {
-		project = ProjectName
+// setConfigRevision records the current revision of the repository as
+// ConfigRevision unless a revision has already been recorded.
+func (g *Gerrit) setConfigRevision(repo *Repository) error {
+	if g.ConfigRevision != "" {
+		return nil
 	}
-
-	logger = logger.WithField("project", project)
-	logger.Debug()
-
-	// Create the project if it does not already exist.
-	if _, response, err := client.Projects.GetProject(project); err != nil {
-		if response.StatusCode == http.StatusNotFound {
-			logger.WithField("action", "create-project").Debug()
-			if _, _, err := client.Projects.CreateProject(project, nil); err != nil {
-				return nil, err
-			}
-		}
+	stdout, _, err := repo.Git([]string{"rev-parse", "HEAD"})
+	if err != nil {
+		return err
 	}
+	g.ConfigRevision = strings.TrimSpace(stdout)
+	return nil
+}
 
-	path, err := ioutil.TempDir("", fmt.Sprintf("%s-", ProjectName))
+// CreateChange creates a new repository with the project as its origin,
+// checks out the tip of branch and commits the subject on top of it. The
+// change is created in Gerrit once it's pushed for review on branch.
+// Branch defaults to master. The project must already exist, see
+// CreateProject() and GetProject(). Branches other than master must also
+// exist, see Project.CreateBranch(), or ErrBranchDoesNotExist is returned.
+// If master does not exist yet, because the project has no commits, the
+// change will be the project's first commit.
+func (g *Gerrit) CreateChange(project *Project, branch string, subject string) (*Change, error) { // nolint: gocyclo
+	if project == nil {
+		return nil, ErrProjectNotProvided
+	}
+	if branch == "" {
+		branch = "master"
+	}
+	logger := g.log.WithFields(log.Fields{
+		"phase":   "create-change",
+		"project": project.Name,
+		"branch":  branch,
+	})
+	logger.Debug()
+	client, err := g.HTTP.Gerrit()
 	if err != nil {
+		logger.WithError(err).Error()
 		return nil, err
 	}
 
-	logger = logger.WithFields(log.Fields{
-		"path":   path,
-		"action": "new-repo",
-	})
-	logger.Debug()
+	logger.WithField("action", "new-repo").Debug()
 	repo, err := NewRepository(g.Config)
 	if err != nil {
 		logger.WithError(err).Error()
@@ -256,20 +429,36 @@ func (g *Gerrit) CreateChange(project string, subject string) (*Change, error) {
 	}
 
 	logger.WithField("action", "add-remote-container").Debug()
-	if err := repo.AddOriginFromContainer(g.Container, project); err != nil {
+	if err := repo.AddOriginFromContainer(g.Container, project.Name); err != nil {
+		repo.Destroy() // nolint: errcheck
+		return nil, err
+	}
+
+	// Gerrit only accepts changes for a branch which does not exist
+	// if it's the project's HEAD, which is master.
+	logger.WithField("action", "checkout").Debug()
+	err = repo.Checkout(branch)
+	if err == ErrBranchDoesNotExist && branch == "master" {
+		err = nil
+	}
+	if err != nil {
+		repo.Destroy() // nolint: errcheck
 		return nil, err
 	}
 
 	logger.WithField("action", "commit").Debug()
 	if err := repo.Commit(subject); err != nil {
+		repo.Destroy() // nolint: errcheck
 		return nil, err
 	}
 	id, err := repo.ChangeID()
 	if err != nil {
+		repo.Destroy() // nolint: errcheck
 		return nil, err
 	}
 	return &Change{
-		api: client,
+		api:  client,
+		http: g.HTTP,
 		log: g.log.WithFields(log.Fields{
 			"cmp": "change",
 			"id":  id,
@@ -279,6 +468,43 @@ func (g *Gerrit) CreateChange(project string, subject string) (*Change, error) {
 	}, nil
 }
 
+// Snapshot commits the container to a local image tagged with the provided
+// reference and returns the id of the image. The json produced by
+// WriteJSONFile() is stored in the LabelState label on the image so later
+// runs can set Config.SnapshotImage to start from the image and skip setup.
+// The image only contains the paths to the private ssh keys, never the
+// keys themselves. Each key is copied to a new file on the host so the
+// image remains usable after Destroy() or GC() remove the originals. The
+// copies are not removed by gerrittest, remove them along with the image.
+func (g *Gerrit) Snapshot(image string) (string, error) {
+	logger := g.log.WithFields(log.Fields{
+		"phase": "snapshot",
+		"image": image,
+	})
+	logger.Debug()
+	keys := []*SSHKey{}
+	for _, key := range g.Config.SSHKeys {
+		copied, err := key.copyTo(ProjectName + "-snapshot-id_rsa-")
+		if err != nil {
+			return "", g.errLog(logger, err)
+		}
+		keys = append(keys, copied)
+	}
+	config := *g.Config
+	config.SSHKeys = keys
+	state := *g
+	state.Config = &config
+	data, err := json.Marshal(&state)
+	if err != nil {
+		return "", err
+	}
+	id, err := g.Container.Commit(image, map[string]string{LabelState: string(data)})
+	if err != nil {
+		return "", g.errLog(logger, err)
+	}
+	return id, nil
+}
+
 // WriteJSONFile takes the current struct and writes the data to disk
 // as json.
 func (g *Gerrit) WriteJSONFile(path string) error {
@@ -296,6 +522,9 @@ func (g *Gerrit) WriteJSONFile(path string) error {
 // private keys or repositories will not be cleaned up.
 func (g *Gerrit) Destroy() error {
 	defer g.cancel()
+	if g.release != nil {
+		defer g.release()
+	}
 	errs := errset.ErrSet{}
 	if g.Config.CleanupContainer && g.Container != nil {
 		errs = append(errs, g.Container.Terminate())
@@ -312,42 +541,105 @@ func (g *Gerrit) Destroy() error {
 	return errs.ReturnValue()
 }
 
-// New constructs and returns a *Gerrit struct after all setup steps have
-// been completed. Once this function returns Gerrit will be running in
-// a container, an admin user will be created and a git repository will
-// be setup pointing at the service in the container.
-func New(cfg *Config) (*Gerrit, error) {
-	ctx, cancel := context.WithCancel(cfg.Context)
-	g := &Gerrit{
-		ctx:    ctx,
-		cancel: cancel,
-		log:    log.WithField("cmp", "core"),
-		Config: cfg,
+// setup performs all setup steps.
+func (g *Gerrit) setup() error {
+	if g.Config.SnapshotImage != "" {
+		if err := g.loadSnapshot(); err != nil {
+			return err
+		}
+	} else if g.Config.SiteDir != "" {
+		if err := g.loadSite(); err != nil {
+			return err
+		}
 	}
 	if err := g.setupSSHKey(); err != nil {
-		return g, err
+		return err
 	}
 	if err := g.startContainer(); err != nil {
-		return g, err
+		return err
+	}
+
+	if g.Config.SkipSetup {
+		return nil
 	}
 
-	if cfg.SkipSetup {
-		return g, nil
+	// Setup was performed before the snapshot was taken, or by a
+	// previous run using the same site, so all we need to do is
+	// construct the clients.
+	if g.restored {
+		client, err := NewHTTPClient(g.Config, g.HTTPPort)
+		if err != nil {
+			return err
+		}
+		g.HTTP = client
+		return g.setupSSHClient()
 	}
 
 	if err := g.setupHTTPClient(); err != nil {
-		return g, err
+		return err
 	}
 	if err := g.setupSSHClient(); err != nil {
-		return g, err
+		return err
 	}
 	if err := g.pushConfig(); err != nil {
-		return g, err
+		return err
+	}
+	if g.Config.SiteDir != "" {
+		return g.WriteJSONFile(filepath.Join(g.Config.SiteDir, SiteStateFile))
+	}
+	return nil
+}
+
+// withLogs returns a *ContainerError containing the original error and the
+// last lines of the container's log. If the container is not running, or
+// the error already contains the logs, the original error is returned.
+func (g *Gerrit) withLogs(err error) error {
+	if _, ok := err.(*ContainerError); ok || g.Container == nil {
+		return err
+	}
+	lines, logErr := g.Container.tailLogs(context.Background(), LogLines)
+	if logErr != nil {
+		g.log.WithError(logErr).Warn()
 	}
+	return &ContainerError{Err: err, ID: g.Container.ID, Logs: lines}
+}
 
+// newGerrit constructs the *Gerrit struct and performs all setup steps.
+func newGerrit(cfg *Config, reuse bool) (*Gerrit, error) {
+	ctx, cancel := context.WithCancel(cfg.Context)
+	g := &Gerrit{
+		ctx:    ctx,
+		cancel: cancel,
+		log:    log.WithField("cmp", "core"),
+		Config: cfg,
+		reuse:  reuse,
+	}
+	if err := g.setup(); err != nil {
+		return g, g.withLogs(err)
+	}
 	return g, nil
 }
 
+// New constructs and returns a *Gerrit struct after all setup steps have
+// been completed. Once this function returns Gerrit will be running in
+// a container, an admin user will be created and a git repository will
+// be setup pointing at the service in the container. If any step fails
+// after the container has been created the returned error will be a
+// *ContainerError which includes the end of the container's log.
+func New(cfg *Config) (*Gerrit, error) {
+	return newGerrit(cfg, false)
+}
+
+// Acquire is similar to New() except it will attach to a running container
+// started by a previous call to Acquire() rather than starting a new one.
+// This avoids waiting for Gerrit to start on every run. Because the container
+// is intended to be reused Config.CleanupContainer is always set to false so
+// Destroy() will leave the container running.
+func Acquire(cfg *Config) (*Gerrit, error) {
+	cfg.CleanupContainer = false
+	return newGerrit(cfg, true)
+}
+
 // LoadJSON strictly loads the json file from the provided path. It makes no
 // attempts to verify that the docker container is running orr
 func LoadJSON(path string) (*Gerrit, error) {
@@ -378,13 +670,15 @@ func NewFromJSON(path string) (*Gerrit, error) {
 
 	logger.WithFields(log.Fields{
 		"path":   path,
-		"action": "get-dockertest-client",
+		"action": "get-runtime",
 	}).Debug()
-	docker, err := dockertest.NewClient()
+	runtime, err := NewDockerRuntime()
 	if err != nil {
 		return nil, err
 	}
-	g.Container.Docker = docker
+	g.Config.Runtime = runtime
+	g.Container.ctx = ctx
+	g.Container.Runtime = runtime
 
 	logger.WithFields(log.Fields{
 		"path":   path,
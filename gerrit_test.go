@@ -1,6 +1,8 @@
 package gerrittest
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -68,6 +70,86 @@ func (s *GerritTest) TestNew(c *C) {
 	c.Assert(gerrit.Destroy(), IsNil)
 }
 
+func (s *GerritTest) TestAcquire(c *C) {
+	if testing.Short() {
+		c.Skip("-short set")
+	}
+
+	first, err := Acquire(NewConfig())
+	c.Assert(err, IsNil)
+	c.Assert(first.Config.CleanupContainer, Equals, false)
+	c.Assert(first.Destroy(), IsNil)
+
+	second, err := Acquire(NewConfig())
+	c.Assert(err, IsNil)
+	c.Assert(second.Container.ID, Equals, first.Container.ID)
+	second.Config.CleanupContainer = true
+	c.Assert(second.Destroy(), IsNil)
+}
+
+func (s *GerritTest) TestSnapshot(c *C) {
+	if testing.Short() {
+		c.Skip("-short set")
+	}
+
+	gerrit, err := New(NewConfig())
+	c.Assert(err, IsNil)
+	image := fmt.Sprintf("%s-snapshot:%s", ProjectName, generaRandomString(8))
+	_, err = gerrit.Snapshot(strings.ToLower(image))
+	c.Assert(err, IsNil)
+	c.Assert(gerrit.Destroy(), IsNil)
+	for _, key := range gerrit.Config.SSHKeys {
+		c.Assert(os.Remove(key.Path), IsNil)
+	}
+
+	cfg := NewConfig()
+	cfg.SnapshotImage = strings.ToLower(image)
+	restored, err := New(cfg)
+	c.Assert(err, IsNil)
+	defer restored.Destroy() // nolint: errcheck
+	for _, key := range restored.Config.SSHKeys {
+		defer os.Remove(key.Path) // nolint: errcheck
+	}
+	c.Assert(restored.Config.Password, Equals, gerrit.Config.Password)
+	_, err = restored.HTTP.Gerrit()
+	c.Assert(err, IsNil)
+}
+
+func (s *GerritTest) TestGerrit_restoreState(c *C) {
+	source := s.gerrit(c)
+	defer os.Remove(s.addSSHKey(c, source)) // nolint: errcheck
+	source.Config.Password = "secret"
+	source.Config.GitConfig["user.email"] = "foo@localhost"
+	data, err := json.Marshal(source)
+	c.Assert(err, IsNil)
+
+	g := s.gerrit(c)
+	c.Assert(g.restoreState(data), IsNil)
+	c.Assert(g.Config.Password, Equals, "secret")
+	c.Assert(g.Config.GitConfig["user.email"], Equals, "foo@localhost")
+	c.Assert(g.Config.SSHKeys, HasLen, 1)
+	c.Assert(g.Config.SSHKeys[0].Private, NotNil)
+	c.Assert(g.Config.SSHKeys[0].Generated, Equals, false)
+}
+
+func (s *GerritTest) TestGerrit_restoreState_noConfig(c *C) {
+	g := s.gerrit(c)
+	c.Assert(g.restoreState([]byte("{}")), Equals, ErrNotSnapshot)
+}
+
+func (s *GerritTest) TestGerrit_withLogs_noContainer(c *C) {
+	g := s.gerrit(c)
+	err := errors.New("failed")
+	c.Assert(g.withLogs(err), Equals, err)
+}
+
+func (s *GerritTest) TestGerrit_withLogs_containerError(c *C) {
+	g := s.gerrit(c)
+	g.Container = &Container{ID: "abc"}
+	err := &ContainerError{Err: errors.New("failed")}
+	c.Assert(g.withLogs(err), Equals, err)
+}
+
 func (s *GerritTest) TestGerrit_setupSSHKey_noPrivateKey(c *C) {
 	g := s.gerrit(c)
 	defer os.Remove(s.addSSHKey(c, g)) // nolint: errcheck
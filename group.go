@@ -0,0 +1,225 @@
+package gerrittest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andygrunwald/go-gerrit"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrGroupNameNotProvided is returned by CreateGroup if no
+// group name was provided.
+var ErrGroupNameNotProvided = errors.New("group name not provided")
+
+// GroupOptions contains optional settings for Gerrit.CreateGroup().
+type GroupOptions struct {
+	// Description is the description of the group.
+	Description string
+
+	// Owner is the name or UUID of the group which owns the new
+	// group. Defaults to the group itself.
+	Owner string
+
+	// VisibleToAll when true makes the group visible to all
+	// registered users.
+	VisibleToAll bool
+
+	// Members contains the usernames of the group's members.
+	Members []string
+
+	// Includes contains the names of groups whose members should
+	// also be members of the new group.
+	Includes []string
+}
+
+// Group is an internal group created by Gerrit.CreateGroup().
+type Group struct {
+	// Name is the name of the group. This is the name access rules
+	// use to refer to the group.
+	Name string `json:"name"`
+
+	// UUID is the unique id of the group.
+	UUID string `json:"uuid"`
+
+	// ID is the numeric id of the group.
+	ID int `json:"id"`
+}
+
+// newGroup returns a *Group from the provided *gerrit.GroupInfo.
+func newGroup(info *gerrit.GroupInfo) (*Group, error) {
+	uuid, err := url.QueryUnescape(info.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Group{Name: info.Name, UUID: uuid, ID: info.GroupID}, nil
+}
+
+// groupPath returns the REST path for the group with the given name.
+func groupPath(name string) string {
+	return "groups/" + url.PathEscape(name)
+}
+
+// CreateGroup creates a new internal group along with its members and
+// included groups. opts may be nil.
+func (g *Gerrit) CreateGroup(name string, opts *GroupOptions) (*Group, error) {
+	if name == "" {
+		return nil, ErrGroupNameNotProvided
+	}
+	if opts == nil {
+		opts = &GroupOptions{}
+	}
+	logger := g.log.WithFields(log.Fields{
+		"phase": "create-group",
+		"group": name,
+	})
+	client, err := g.HTTP.Gerrit()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.WithField("action", "create").Debug()
+	info, _, err := client.Groups.CreateGroup(url.PathEscape(name), &gerrit.GroupInput{
+		Description:  opts.Description,
+		VisibleToAll: opts.VisibleToAll,
+		OwnerID:      opts.Owner,
+	})
+	if err != nil {
+		return nil, err
+	}
+	group, err := newGroup(info)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.AddMembers(name, opts.Members...); err != nil {
+		return nil, err
+	}
+	for _, include := range opts.Includes {
+		if err := g.IncludeGroup(name, include); err != nil {
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+// GetGroup returns the group with the given name.
+func (g *Gerrit) GetGroup(name string) (*Group, error) {
+	client, err := g.HTTP.Gerrit()
+	if err != nil {
+		return nil, err
+	}
+	info, _, err := client.Groups.GetGroup(url.PathEscape(name))
+	if err != nil {
+		return nil, err
+	}
+	return newGroup(info)
+}
+
+// AddMembers adds the accounts with the provided usernames to the group.
+func (g *Gerrit) AddMembers(group string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	g.log.WithFields(log.Fields{
+		"phase":   "add-members",
+		"group":   group,
+		"members": members,
+	}).Debug()
+	client, err := g.HTTP.Gerrit()
+	if err != nil {
+		return err
+	}
+	_, _, err = client.Groups.AddGroupMembers(url.PathEscape(group), &gerrit.MembersInput{
+		Members: members,
+	})
+	return err
+}
+
+// RemoveMembers removes the accounts with the provided usernames from
+// the group.
+func (g *Gerrit) RemoveMembers(group string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	g.log.WithFields(log.Fields{
+		"phase":   "remove-members",
+		"group":   group,
+		"members": members,
+	}).Debug()
+
+	// go-gerrit's DeleteGroupMembers() produces an invalid url so the
+	// request is made directly.
+	body, err := json.Marshal(&gerrit.MembersInput{Members: members})
+	if err != nil {
+		return err
+	}
+	response, responseBody, err := g.HTTP.API(
+		http.MethodPost, groupPath(group)+"/members.delete", body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf(
+			"failed to remove members from %s: %d %s", group, response.StatusCode,
+			strings.TrimSpace(string(responseBody)))
+	}
+	return nil
+}
+
+// IncludeGroup makes the members of include members of group.
+func (g *Gerrit) IncludeGroup(group string, include string) error {
+	g.log.WithFields(log.Fields{
+		"phase":   "include-group",
+		"group":   group,
+		"include": include,
+	}).Debug()
+	client, err := g.HTTP.Gerrit()
+	if err != nil {
+		return err
+	}
+	_, _, err = client.Groups.IncludeGroup(url.PathEscape(group), url.PathEscape(include))
+	return err
+}
+
+// AccessRule grants a permission on a ref pattern to a group.
+type AccessRule struct {
+	// Ref is the ref pattern the rule applies to, for
+	// example refs/heads/*.
+	Ref string
+
+	// Permission is the name of the permission, for example read,
+	// submit or label-Verified.
+	Permission string
+
+	// Range is the range of values which may be voted for label
+	// permissions, for example -1..+1. It should be empty for other
+	// permissions.
+	Range string
+
+	// Group is the name of the group to grant the permission to.
+	Group string
+}
+
+// validate returns an error if a required field is missing.
+func (r *AccessRule) validate() error {
+	switch {
+	case r.Ref == "":
+		return errors.New("access rule: ref not provided")
+	case r.Permission == "":
+		return errors.New("access rule: permission not provided")
+	case r.Group == "":
+		return errors.New("access rule: group not provided")
+	}
+	return nil
+}
+
+// Grant adds the access rules to the project's project.config. Groups
+// are referred to by name and are added to the project's groups file
+// if they are not already present.
+func (g *Gerrit) Grant(project string, rules ...*AccessRule) error {
+	return g.PushProjectConfig(project, NewProjectConfig().Grant(rules...))
+}
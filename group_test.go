@@ -0,0 +1,96 @@
+package gerrittest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andygrunwald/go-gerrit"
+	log "github.com/sirupsen/logrus"
+	. "gopkg.in/check.v1"
+)
+
+type GroupTest struct{}
+
+var _ = Suite(&GroupTest{})
+
+func (s *GroupTest) TestNewGroup(c *C) {
+	group, err := newGroup(&gerrit.GroupInfo{
+		ID:      "global%3ARegistered-Users",
+		Name:    "Registered Users",
+		GroupID: 2,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(group, DeepEquals, &Group{
+		Name: "Registered Users", UUID: "global:Registered-Users", ID: 2})
+}
+
+func (s *GroupTest) TestCreateGroup_noName(c *C) {
+	_, err := (&Gerrit{}).CreateGroup("", nil)
+	c.Assert(err, Equals, ErrGroupNameNotProvided)
+}
+
+func (s *GroupTest) TestRemoveMembers(c *C) {
+	expected := httptest.NewRecorder()
+	expected.Code = http.StatusNoContent
+	client, handler, server := newClient(expected)
+	defer server.Close()
+	client.config.Username = "admin"
+	client.config.Password = "secret"
+	g := &Gerrit{HTTP: client, log: log.WithField("cmp", "gerrit")}
+	c.Assert(g.RemoveMembers("CI Bots", "jdoe"), IsNil)
+	c.Assert(handler.Request().Method, Equals, http.MethodPost)
+	c.Assert(handler.Request().URL.Path, Equals, "/a/groups/CI Bots/members.delete")
+	c.Assert(handler.RequestBody(), Equals, `{"members":["jdoe"]}`)
+}
+
+func (s *GroupTest) TestRemoveMembers_error(c *C) {
+	expected := httptest.NewRecorder()
+	expected.Code = http.StatusNotFound
+	expected.Body.Write([]byte("Not found: CI"))
+	client, _, server := newClient(expected)
+	defer server.Close()
+	client.config.Username = "admin"
+	client.config.Password = "secret"
+	g := &Gerrit{HTTP: client, log: log.WithField("cmp", "gerrit")}
+	c.Assert(
+		g.RemoveMembers("CI", "jdoe"), ErrorMatches,
+		"failed to remove members from CI: 404 Not found: CI")
+}
+
+func (s *GroupTest) TestAccessRule_validate(c *C) {
+	c.Assert((&AccessRule{Ref: "refs/*", Permission: "read", Group: "CI"}).validate(), IsNil)
+	c.Assert(
+		(&AccessRule{Permission: "read", Group: "CI"}).validate(), ErrorMatches,
+		"access rule: ref not provided")
+	c.Assert(
+		(&AccessRule{Ref: "refs/*", Group: "CI"}).validate(), ErrorMatches,
+		"access rule: permission not provided")
+	c.Assert(
+		(&AccessRule{Ref: "refs/*", Permission: "read"}).validate(), ErrorMatches,
+		"access rule: group not provided")
+}
+
+func (s *GroupTest) TestGrant(c *C) {
+	if testing.Short() {
+		c.Skip("-short provided")
+	}
+	g, err := New(NewConfig())
+	c.Assert(err, IsNil)
+	defer g.Destroy() // nolint: errcheck
+
+	user, err := g.CreateUser("ci", "ci@localhost", nil)
+	c.Assert(err, IsNil)
+	defer user.Destroy() // nolint: errcheck
+
+	group, err := g.CreateGroup("CI", &GroupOptions{Members: []string{"ci"}})
+	c.Assert(err, IsNil)
+	c.Assert(group.Name, Equals, "CI")
+	c.Assert(g.Grant("All-Projects", &AccessRule{
+		Ref:        "refs/heads/*",
+		Permission: "label-Verified",
+		Range:      "-1..+1",
+		Group:      "CI",
+	}), IsNil)
+	c.Assert(g.RemoveMembers("CI", "ci"), IsNil)
+}
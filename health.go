@@ -0,0 +1,189 @@
+package gerrittest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/opalmer/dockertest"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrNotStarted is returned by Gerrit.Health() checks if the container
+	// or its ports are not known.
+	ErrNotStarted = errors.New("container not started")
+)
+
+// HealthCheck is the result of a single check performed by Gerrit.Health().
+type HealthCheck struct {
+	// OK is true if the check passed.
+	OK bool `json:"ok"`
+
+	// Detail contains additional information about the result, such as
+	// the state of the container or the version of Gerrit.
+	Detail string `json:"detail,omitempty"`
+
+	// Error contains the reason the check failed.
+	Error string `json:"error,omitempty"`
+}
+
+// newHealthCheck constructs a HealthCheck from the provided detail
+// and error.
+func newHealthCheck(detail string, err error) HealthCheck {
+	if err != nil {
+		return HealthCheck{OK: false, Detail: detail, Error: err.Error()}
+	}
+	return HealthCheck{OK: true, Detail: detail}
+}
+
+// HealthReport is returned by Gerrit.Health().
+type HealthReport struct {
+	// Healthy is true if all checks passed.
+	Healthy bool `json:"healthy"`
+
+	// Container reports the state of the container according to
+	// the container's Runtime.
+	Container HealthCheck `json:"container"`
+
+	// HTTP reports if Gerrit's web interface is reachable.
+	HTTP HealthCheck `json:"http"`
+
+	// Account reports if the admin account can authenticate with the
+	// REST API.
+	Account HealthCheck `json:"account"`
+
+	// SSH reports if we're able to connect over ssh.
+	SSH HealthCheck `json:"ssh"`
+
+	// Version reports the result of running 'gerrit version' over ssh.
+	Version HealthCheck `json:"version"`
+}
+
+// checkContainer checks the state of the container using the
+// container's Runtime.
+func (g *Gerrit) checkContainer(ctx context.Context) HealthCheck {
+	if g.Container == nil {
+		return newHealthCheck("", ErrNotStarted)
+	}
+	runtime, err := g.Container.runtime()
+	if err != nil {
+		return newHealthCheck("", err)
+	}
+	state, err := runtime.Inspect(ctx, g.Container.ID)
+	if err != nil {
+		return newHealthCheck("", err)
+	}
+	if !state.Running {
+		return newHealthCheck(state.Status, dockertest.ErrContainerNotRunning)
+	}
+	return newHealthCheck(state.Status, nil)
+}
+
+// checkHTTP checks that Gerrit's web interface responds.
+func (g *Gerrit) checkHTTP(ctx context.Context) HealthCheck {
+	if g.HTTPPort == nil {
+		return newHealthCheck("", ErrNotStarted)
+	}
+	url := fmt.Sprintf("http://%s:%d/", g.HTTPPort.Address, g.HTTPPort.Public)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return newHealthCheck("", err)
+	}
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return newHealthCheck("", err)
+	}
+	defer response.Body.Close() // nolint: errcheck
+	if response.StatusCode != http.StatusOK {
+		return newHealthCheck(response.Status, fmt.Errorf(
+			"response code %d != %d", response.StatusCode, http.StatusOK))
+	}
+	return newHealthCheck(response.Status, nil)
+}
+
+// checkAccount checks that the admin account can use the REST API.
+func (g *Gerrit) checkAccount(ctx context.Context) HealthCheck {
+	if g.HTTPPort == nil {
+		return newHealthCheck("", ErrNotStarted)
+	}
+	client := g.HTTP
+	if client == nil {
+		created, err := NewHTTPClient(g.Config, g.HTTPPort)
+		if err != nil {
+			return newHealthCheck("", err)
+		}
+		client = created
+	}
+	if !client.authenticated() {
+		return newHealthCheck("", errors.New("username and password required"))
+	}
+	request, err := client.newRequest(http.MethodGet, "/a/accounts/self", nil)
+	if err != nil {
+		return newHealthCheck("", err)
+	}
+	_, body, err := client.do(request.WithContext(ctx), http.StatusOK)
+	if err != nil {
+		return newHealthCheck("", err)
+	}
+	account := &gerrit.AccountInfo{}
+	if err := json.Unmarshal(body, account); err != nil {
+		return newHealthCheck("", err)
+	}
+	return newHealthCheck(account.Username, nil)
+}
+
+// checkSSH checks that we can connect over ssh and run 'gerrit version'.
+func (g *Gerrit) checkSSH(ctx context.Context) (HealthCheck, HealthCheck) {
+	if g.SSHPort == nil {
+		return newHealthCheck("", ErrNotStarted), newHealthCheck("", ErrNotStarted)
+	}
+	client := g.SSH
+	if client == nil {
+		for _, key := range g.Config.SSHKeys {
+			if key.Private != nil {
+				continue
+			}
+			if err := key.load(); err != nil {
+				return newHealthCheck("", err), newHealthCheck("", err)
+			}
+		}
+		created, err := newSSHClient(ctx, g.Config, g.SSHPort)
+		if created != nil {
+			defer created.Close() // nolint: errcheck
+		}
+		if err != nil {
+			return newHealthCheck("", err), newHealthCheck("", err)
+		}
+		client = created
+	}
+	address := fmt.Sprintf("%s:%d", g.SSHPort.Address, g.SSHPort.Public)
+	version, err := client.version(ctx)
+	return newHealthCheck(address, nil), newHealthCheck(version, err)
+}
+
+// Health checks the state of the container, Gerrit's web interface, the
+// REST API and ssh. This may be called on a struct produced by LoadJSON()
+// in which case the http and ssh clients will be created as needed.
+func (g *Gerrit) Health(ctx context.Context) *HealthReport {
+	logger := g.log.WithField("phase", "health")
+	report := &HealthReport{}
+	report.Container = g.checkContainer(ctx)
+	report.HTTP = g.checkHTTP(ctx)
+	report.Account = g.checkAccount(ctx)
+	report.SSH, report.Version = g.checkSSH(ctx)
+	report.Healthy = report.Container.OK && report.HTTP.OK &&
+		report.Account.OK && report.SSH.OK && report.Version.OK
+	logger.WithFields(log.Fields{
+		"healthy":   report.Healthy,
+		"container": report.Container.OK,
+		"http":      report.HTTP.OK,
+		"account":   report.Account.OK,
+		"ssh":       report.SSH.OK,
+		"version":   report.Version.OK,
+	}).Debug()
+	return report
+}
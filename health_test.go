@@ -0,0 +1,137 @@
+package gerrittest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opalmer/dockertest"
+	log "github.com/sirupsen/logrus"
+	. "gopkg.in/check.v1"
+)
+
+type HealthTest struct{}
+
+var _ = Suite(&HealthTest{})
+
+func (s *HealthTest) gerrit(c *C, server *httptest.Server) *Gerrit {
+	g := &Gerrit{
+		Config: NewConfig(),
+		log:    log.WithField("cmp", "core"),
+	}
+	if server != nil {
+		split := strings.Split(server.Listener.Addr().String(), ":")
+		port, err := strconv.ParseUint(split[1], 10, 16)
+		c.Assert(err, IsNil)
+		g.HTTPPort = &dockertest.Port{Address: split[0], Public: uint16(port)}
+	}
+	return g
+}
+
+func (s *HealthTest) Test_newHealthCheck(c *C) {
+	c.Assert(newHealthCheck("foo", nil), DeepEquals, HealthCheck{OK: true, Detail: "foo"})
+	c.Assert(
+		newHealthCheck("foo", errors.New("bar")), DeepEquals,
+		HealthCheck{OK: false, Detail: "foo", Error: "bar"})
+}
+
+func (s *HealthTest) TestGerrit_checkHTTP(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	check := s.gerrit(c, ts).checkHTTP(context.Background())
+	c.Assert(check.OK, Equals, true)
+}
+
+func (s *HealthTest) TestGerrit_checkHTTP_badStatus(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	check := s.gerrit(c, ts).checkHTTP(context.Background())
+	c.Assert(check.OK, Equals, false)
+	c.Assert(check.Error, Equals, "response code 503 != 200")
+}
+
+func (s *HealthTest) TestGerrit_checkAccount(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"_account_id": 1000000, "username": "admin"}`)
+	}))
+	defer ts.Close()
+	g := s.gerrit(c, ts)
+	g.Config.Password = "secret"
+	check := g.checkAccount(context.Background())
+	c.Assert(check.OK, Equals, true)
+	c.Assert(check.Detail, Equals, "admin")
+}
+
+func (s *HealthTest) TestGerrit_checkAccount_noPassword(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	check := s.gerrit(c, ts).checkAccount(context.Background())
+	c.Assert(check.OK, Equals, false)
+	c.Assert(check.Error, Equals, "username and password required")
+}
+
+func (s *HealthTest) TestGerrit_checkAccount_timeout(c *C) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+	g := s.gerrit(c, ts)
+	g.Config.Password = "secret"
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	check := g.checkAccount(ctx)
+	c.Assert(check.OK, Equals, false)
+	c.Assert(check.Error, Matches, ".*"+context.DeadlineExceeded.Error())
+}
+
+func (s *HealthTest) TestGerrit_checkSSH_timeout(c *C) {
+	// The listener accepts connections but never performs the ssh
+	// handshake so only the context can end the check.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer listener.Close() // nolint: errcheck
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close() // nolint: errcheck
+		}
+	}()
+	key, err := NewSSHKey()
+	c.Assert(err, IsNil)
+	defer os.Remove(key.Path) // nolint: errcheck
+	g := s.gerrit(c, nil)
+	g.Config.SSHKeys = []*SSHKey{key}
+	address := listener.Addr().(*net.TCPAddr)
+	g.SSHPort = &dockertest.Port{Address: "127.0.0.1", Public: uint16(address.Port)}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	sshCheck, versionCheck := g.checkSSH(ctx)
+	c.Assert(sshCheck.OK, Equals, false)
+	c.Assert(versionCheck.OK, Equals, false)
+	c.Assert(sshCheck.Error, Equals, context.DeadlineExceeded.Error())
+}
+
+func (s *HealthTest) TestGerrit_Health_notStarted(c *C) {
+	report := s.gerrit(c, nil).Health(context.Background())
+	c.Assert(report.Healthy, Equals, false)
+	for _, check := range []HealthCheck{
+		report.Container, report.HTTP, report.Account, report.SSH, report.Version} {
+		c.Assert(check.Error, Equals, ErrNotStarted.Error())
+	}
+}
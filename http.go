@@ -36,6 +36,11 @@ func getResponseBody(response *http.Response) ([]byte, error) {
 type HTTPClient struct {
 	client *http.Client
 	config *Config
+
+	// session is true when requests are authenticated using the
+	// session cookie login() received rather than a password. See As().
+	session bool
+
 	Prefix string
 }
 
@@ -70,12 +75,7 @@ func (h *HTTPClient) newRequest(method string, tail string, body []byte) (*http.
 		request.Header.Add("X-User", h.config.Username)
 	}
 
-	for _, cookie := range h.client.Jar.Cookies(&url.URL{Host: "localhost"}) {
-		request.AddCookie(cookie)
-		if cookie.Name == "XSRF_TOKEN" {
-			request.Header.Set("X-Gerrit-Auth", cookie.Value)
-		}
-	}
+	h.addSession(request)
 
 	log.WithFields(log.Fields{
 		"action": "request",
@@ -87,6 +87,36 @@ func (h *HTTPClient) newRequest(method string, tail string, body []byte) (*http.
 	return request, nil
 }
 
+// addSession adds the cookies received from Gerrit, including the
+// session cookie set by login(), and the XSRF token to the request.
+func (h *HTTPClient) addSession(request *http.Request) {
+	for _, cookie := range h.client.Jar.Cookies(&url.URL{Host: "localhost"}) {
+		request.AddCookie(cookie)
+		if cookie.Name == "XSRF_TOKEN" {
+			request.Header.Set("X-Gerrit-Auth", cookie.Value)
+		}
+	}
+}
+
+// sessionTransport is used by the go-gerrit client Gerrit() returns for
+// clients produced by As() so requests are authenticated using the
+// session cookie instead of a password.
+type sessionTransport struct {
+	client *HTTPClient
+}
+
+// RoundTrip adds the session to a copy of the request and then
+// performs the request.
+func (t *sessionTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	copied := *request
+	copied.Header = http.Header{}
+	for key, values := range request.Header {
+		copied.Header[key] = values
+	}
+	t.client.addSession(&copied)
+	return http.DefaultTransport.RoundTrip(&copied)
+}
+
 // do performs the request using the internal http client.
 func (h *HTTPClient) do(request *http.Request, expectedCode int) (*http.Response, []byte, error) {
 	logger := log.WithFields(log.Fields{
@@ -137,20 +167,87 @@ func (h *HTTPClient) login() error {
 	return err
 }
 
+// authenticated returns true if the client has a password or a session
+// to authenticate requests with.
+func (h *HTTPClient) authenticated() bool {
+	return h.session || (h.config.Username != "" && h.config.Password != "")
+}
+
+// activate reactivates the account username, for example one which
+// Reset() deactivated. Accounts which don't exist yet are ignored because
+// logging in creates them, as are clients which are not allowed to
+// modify accounts.
+func (h *HTTPClient) activate(username string) error {
+	if !h.authenticated() {
+		return nil
+	}
+	path := "/accounts/" + url.PathEscape(username) + "/active"
+	response, _, err := h.API(http.MethodPut, path, nil)
+	if err != nil {
+		return err
+	}
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNotFound, http.StatusForbidden:
+		return nil
+	}
+	return fmt.Errorf("PUT %s: %s", path, response.Status)
+}
+
+// As returns a new *HTTPClient which performs requests as username
+// without requiring a password. Gerrit trusts the X-User header sent
+// when logging in and will create the account if it does not already
+// exist. Inactive accounts are reactivated first. The returned client
+// authenticates using the resulting session so API() and Gerrit() may be
+// used as normal.
+func (h *HTTPClient) As(username string) (*HTTPClient, error) {
+	if username == "" {
+		return nil, errors.New("username not provided")
+	}
+	if err := h.activate(username); err != nil {
+		return nil, err
+	}
+	config := *h.config
+	config.Username = username
+	config.Password = ""
+	client := &HTTPClient{
+		config:  &config,
+		client:  &http.Client{Jar: NewCookieJar()},
+		session: true,
+		Prefix:  h.Prefix,
+	}
+	log.WithFields(log.Fields{
+		"phase":    "as",
+		"username": username,
+	}).Debug()
+	if err := client.login(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
 // Gerrit will return a *gerrit.Gerrit client. Note, the username
-// and password must already be set and basic validation to ensure
-// the client is setup properly is performed.
+// and password must already be set, unless the client was produced by
+// As(), and basic validation to ensure the client is setup properly
+// is performed.
 func (h *HTTPClient) Gerrit() (*gerrit.Client, error) {
-	if h.config.Username == "" || h.config.Password == "" {
+	if !h.authenticated() {
 		return nil, errors.New("username and password required")
 	}
 	parsed, err := url.Parse(h.Prefix)
 	if err != nil {
 		return nil, err
 	}
-	client, err := gerrit.NewClient(fmt.Sprintf(
-		"%s://%s:%s@%s", parsed.Scheme, h.config.Username, h.config.Password,
-		parsed.Host), nil)
+
+	var client *gerrit.Client
+	if h.session {
+		client, err = gerrit.NewClient(h.url("/a/"), &http.Client{
+			Transport: &sessionTransport{client: h},
+		})
+	} else {
+		client, err = gerrit.NewClient(fmt.Sprintf(
+			"%s://%s:%s@%s", parsed.Scheme, h.config.Username, h.config.Password,
+			parsed.Host), nil)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -161,6 +258,28 @@ func (h *HTTPClient) Gerrit() (*gerrit.Client, error) {
 	return client, nil
 }
 
+// API performs an authenticated request against Gerrit's REST API and
+// returns the response along with the body, with Gerrit's magic prefix
+// removed. The /a prefix Gerrit uses for authenticated requests is added
+// to path if it's not already present. Unlike go-gerrit a response code
+// indicating failure is not considered an error.
+func (h *HTTPClient) API(method string, path string, body []byte) (*http.Response, []byte, error) {
+	if !h.authenticated() {
+		return nil, nil, errors.New("username and password required")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if !strings.HasPrefix(path, "/a/") {
+		path = "/a" + path
+	}
+	request, err := h.newRequest(method, path, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h.do(request, 0)
+}
+
 // generatePassword generates and returns the account password. Note, this
 // only works for the current account (the one which set the cookie
 // in GetAccount())
@@ -236,6 +355,12 @@ func (h *HTTPClient) configureEmail() error {
 		return err
 	}
 
+	// Nothing to do if the email was already configured, such as when
+	// we've attached to an existing container.
+	if account.Email == h.config.GitConfig["user.email"] {
+		return nil
+	}
+
 	_, _, err = g.Accounts.CreateAccountEmail(account.Username, h.config.GitConfig["user.email"], &gerrit.EmailInput{
 		Email:          h.config.GitConfig["user.email"],
 		Preferred:      true,
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"sync"
 
 	"github.com/andygrunwald/go-gerrit"
@@ -126,6 +127,132 @@ func (s *HTTPTest) TestHTTPClient_Do(c *C) {
 	c.Assert(body, DeepEquals, []byte("hello"))
 }
 
+func (s *HTTPTest) TestHTTPClient_API(c *C) {
+	expected := httptest.NewRecorder()
+	expected.Code = http.StatusNotFound
+	expected.Body.Write([]byte(")]}'\n{}"))
+	client, handler, server := newClient(expected)
+	defer server.Close()
+	client.config.Username = "admin"
+	client.config.Password = "secret"
+	response, body, err := client.API(http.MethodPut, "changes/1", []byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusNotFound)
+	c.Assert(body, DeepEquals, []byte("{}"))
+	request := handler.Request()
+	c.Assert(request.Method, Equals, http.MethodPut)
+	c.Assert(request.URL.Path, Equals, "/a/changes/1")
+	c.Assert(handler.RequestBody(), Equals, "foo")
+	username, password, ok := request.BasicAuth()
+	c.Assert(ok, Equals, true)
+	c.Assert(username, Equals, "admin")
+	c.Assert(password, Equals, "secret")
+}
+
+func (s *HTTPTest) TestHTTPClient_API_noPassword(c *C) {
+	client, _, server := newClient(nil)
+	server.Close()
+	_, _, err := client.API(http.MethodGet, "/a/changes/", nil)
+	c.Assert(err, ErrorMatches, "username and password required")
+}
+
+func (s *HTTPTest) TestHTTPClient_As(c *C) {
+	expected := httptest.NewRecorder()
+	expected.Code = http.StatusOK
+	client, handler, server := newClient(expected)
+	defer server.Close()
+	client.config.Username = "admin"
+	client.config.Password = "secret"
+	as, err := client.As("jdoe")
+	c.Assert(err, IsNil)
+	c.Assert(as.session, Equals, true)
+	c.Assert(as.config.Username, Equals, "jdoe")
+	c.Assert(as.config.Password, Equals, "")
+	c.Assert(as.Prefix, Equals, client.Prefix)
+	c.Assert(client.config.Username, Equals, "admin")
+
+	request := handler.Request()
+	c.Assert(request.URL.Path, Equals, "/login/")
+	c.Assert(request.Header.Get("X-User"), Equals, "jdoe")
+	_, _, ok := request.BasicAuth()
+	c.Assert(ok, Equals, false)
+}
+
+func (s *HTTPTest) TestHTTPClient_As_activates(c *C) {
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	}))
+	defer ts.Close()
+	client := &HTTPClient{
+		config: &Config{Username: "admin", Password: "secret"},
+		client: &http.Client{Jar: NewCookieJar()},
+		Prefix: ts.URL,
+	}
+	_, err := client.As("jdoe")
+	c.Assert(err, IsNil)
+	c.Assert(requests, DeepEquals, []string{
+		"PUT /a/accounts/jdoe/active", "GET /login/"})
+}
+
+func (s *HTTPTest) TestHTTPClient_As_activateError(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	client := &HTTPClient{
+		config: &Config{Username: "admin", Password: "secret"},
+		client: &http.Client{Jar: NewCookieJar()},
+		Prefix: ts.URL,
+	}
+	_, err := client.As("jdoe")
+	c.Assert(err, ErrorMatches, "PUT /accounts/jdoe/active: 500 Internal Server Error")
+}
+
+func (s *HTTPTest) TestHTTPClient_As_noUsername(c *C) {
+	client, _, server := newClient(nil)
+	server.Close()
+	_, err := client.As("")
+	c.Assert(err, ErrorMatches, "username not provided")
+}
+
+func (s *HTTPTest) TestHTTPClient_API_session(c *C) {
+	expected := httptest.NewRecorder()
+	expected.Code = http.StatusOK
+	client, handler, server := newClient(expected)
+	defer server.Close()
+	client.config.Username = "jdoe"
+	client.session = true
+	client.client.Jar.SetCookies(
+		&url.URL{Host: "localhost"}, []*http.Cookie{{Name: "XSRF_TOKEN", Value: "token"}})
+	_, _, err := client.API(http.MethodPost, "changes/1/abandon", nil)
+	c.Assert(err, IsNil)
+	request := handler.Request()
+	c.Assert(request.Header.Get("X-Gerrit-Auth"), Equals, "token")
+	_, _, ok := request.BasicAuth()
+	c.Assert(ok, Equals, false)
+}
+
+func (s *HTTPTest) TestSessionTransport(c *C) {
+	expected := httptest.NewRecorder()
+	expected.Code = http.StatusOK
+	client, handler, server := newClient(expected)
+	defer server.Close()
+	client.client.Jar.SetCookies(
+		&url.URL{Host: "localhost"}, []*http.Cookie{{Name: "XSRF_TOKEN", Value: "token"}})
+	request, err := http.NewRequest(http.MethodPost, client.url("/a/changes/1/abandon"), nil)
+	c.Assert(err, IsNil)
+	response, err := (&sessionTransport{client: client}).RoundTrip(request)
+	c.Assert(err, IsNil)
+	c.Assert(response.Body.Close(), IsNil)
+	c.Assert(request.Header.Get("X-Gerrit-Auth"), Equals, "")
+	received := handler.Request()
+	c.Assert(received.Header.Get("X-Gerrit-Auth"), Equals, "token")
+	cookie, err := received.Cookie("XSRF_TOKEN")
+	c.Assert(err, IsNil)
+	c.Assert(cookie.Value, Equals, "token")
+}
+
 func (s *HTTPTest) TestHTTPClient_Login(c *C) {
 	expected := httptest.NewRecorder()
 	expected.Code = http.StatusOK
@@ -0,0 +1,158 @@
+package gerrittest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/crewjam/errset"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrPoolClosed is returned by Pool.Get() if the pool has been closed.
+	ErrPoolClosed = errors.New("pool closed")
+
+	// ErrPoolSizeNegative is returned by NewPool() if size is negative.
+	ErrPoolSizeNegative = errors.New("pool size must not be negative")
+)
+
+// poolEntry is the result of starting a single instance in the background.
+type poolEntry struct {
+	gerrit *Gerrit
+	err    error
+}
+
+// Pool keeps a number of fully setup *Gerrit instances ready in the
+// background so they can be handed out to tests running in parallel. Each
+// instance handed out by Get() is replaced by a new one once it's destroyed,
+// either by calling Put() or Gerrit.Destroy(). Use NewPool() to construct
+// this struct.
+type Pool struct {
+	log       *log.Entry
+	mtx       *sync.Mutex
+	wg        *sync.WaitGroup
+	ready     chan *poolEntry
+	closed    bool
+	newConfig func() *Config
+	newGerrit func(*Config) (*Gerrit, error)
+}
+
+// start sets up a single instance in the background. The caller must be
+// holding the lock.
+func (p *Pool) start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		logger := p.log.WithField("phase", "start")
+		logger.WithField("task", "begin").Debug()
+		gerrit, err := p.newGerrit(p.newConfig())
+		if err != nil {
+			logger.WithError(err).Error()
+			if gerrit != nil {
+				gerrit.Destroy() // nolint: errcheck
+			}
+			p.ready <- &poolEntry{err: err}
+			return
+		}
+
+		once := &sync.Once{}
+		gerrit.release = func() { once.Do(p.replace) }
+		logger.WithField("task", "end").Debug()
+		p.ready <- &poolEntry{gerrit: gerrit}
+	}()
+}
+
+// replace starts a new instance to replace one which has been taken
+// out of the pool and destroyed.
+func (p *Pool) replace() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.closed {
+		return
+	}
+	p.start()
+}
+
+// Get returns a *Gerrit instance from the pool, waiting for one to become
+// ready if necessary. Call Put() or Gerrit.Destroy() when you're done with
+// the instance so it can be replaced.
+func (p *Pool) Get(ctx context.Context) (*Gerrit, error) {
+	p.mtx.Lock()
+	closed := p.closed
+	p.mtx.Unlock()
+	if closed {
+		return nil, ErrPoolClosed
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case entry := <-p.ready:
+		if entry.err != nil {
+			p.replace()
+		}
+		return entry.gerrit, entry.err
+	}
+}
+
+// Put returns an instance to the pool. The instance is destroyed, because
+// its state is no longer known, and a new one is started in its place.
+func (p *Pool) Put(gerrit *Gerrit) error {
+	return gerrit.Destroy()
+}
+
+// Close waits for any instances that are still being setup and then
+// destroys every instance which has not been handed out by Get().
+func (p *Pool) Close() error {
+	p.mtx.Lock()
+	p.closed = true
+	p.mtx.Unlock()
+	p.wg.Wait()
+
+	errs := errset.ErrSet{}
+	for {
+		select {
+		case entry := <-p.ready:
+			if entry.gerrit != nil {
+				entry.gerrit.release = nil
+				errs = append(errs, entry.gerrit.Destroy())
+			}
+		default:
+			return errs.ReturnValue()
+		}
+	}
+}
+
+// newPool constructs a *Pool using the provided function to start
+// each instance.
+func newPool(size int, newConfig func() *Config, newGerrit func(*Config) (*Gerrit, error)) (*Pool, error) {
+	if size < 0 {
+		return nil, ErrPoolSizeNegative
+	}
+	if newConfig == nil {
+		newConfig = NewConfig
+	}
+	pool := &Pool{
+		log:       log.WithField("cmp", "pool"),
+		mtx:       &sync.Mutex{},
+		wg:        &sync.WaitGroup{},
+		ready:     make(chan *poolEntry, size),
+		newConfig: newConfig,
+		newGerrit: newGerrit,
+	}
+	pool.mtx.Lock()
+	defer pool.mtx.Unlock()
+	for i := 0; i < size; i++ {
+		pool.start()
+	}
+	return pool, nil
+}
+
+// NewPool constructs a *Pool and starts setting up size instances of Gerrit
+// in the background. newConfig is called once for every instance started; if
+// nil then NewConfig() will be used. ErrPoolSizeNegative is returned if
+// size is negative.
+func NewPool(size int, newConfig func() *Config) (*Pool, error) {
+	return newPool(size, newConfig, New)
+}
@@ -0,0 +1,110 @@
+package gerrittest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	. "gopkg.in/check.v1"
+)
+
+type PoolTest struct{}
+
+var _ = Suite(&PoolTest{})
+
+// poolCounter counts the number of instances started by a *Pool.
+type poolCounter struct {
+	mtx     *sync.Mutex
+	started int
+	err     error
+}
+
+func (p *poolCounter) new(cfg *Config) (*Gerrit, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.started++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &Gerrit{
+		cancel: func() {},
+		log:    log.WithField("cmp", "core"),
+		Config: cfg,
+	}, nil
+}
+
+func (p *poolCounter) count() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.started
+}
+
+func (s *PoolTest) TestPool_Get(c *C) {
+	counter := &poolCounter{mtx: &sync.Mutex{}}
+	pool, err := newPool(2, nil, counter.new)
+	c.Assert(err, IsNil)
+	defer pool.Close() // nolint: errcheck
+
+	for i := 0; i < 2; i++ {
+		gerrit, err := pool.Get(context.Background())
+		c.Assert(err, IsNil)
+		c.Assert(gerrit, NotNil)
+	}
+	c.Assert(counter.count(), Equals, 2)
+}
+
+func (s *PoolTest) TestPool_Get_contextCancelled(c *C) {
+	counter := &poolCounter{mtx: &sync.Mutex{}}
+	pool, err := newPool(0, nil, counter.new)
+	c.Assert(err, IsNil)
+	defer pool.Close() // nolint: errcheck
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pool.Get(ctx)
+	c.Assert(err, ErrorMatches, context.Canceled.Error())
+}
+
+func (s *PoolTest) TestPool_Get_error(c *C) {
+	counter := &poolCounter{mtx: &sync.Mutex{}, err: errors.New("failed")}
+	pool, err := newPool(1, nil, counter.new)
+	c.Assert(err, IsNil)
+	_, err = pool.Get(context.Background())
+	c.Assert(err, ErrorMatches, "failed")
+	c.Assert(pool.Close(), IsNil)
+	c.Assert(counter.count(), Equals, 2)
+}
+
+func (s *PoolTest) TestPool_Put(c *C) {
+	counter := &poolCounter{mtx: &sync.Mutex{}}
+	pool, err := newPool(1, nil, counter.new)
+	c.Assert(err, IsNil)
+	gerrit, err := pool.Get(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(pool.Put(gerrit), IsNil)
+
+	// Destroying the instance more than once should not cause
+	// more than one replacement.
+	c.Assert(gerrit.Destroy(), IsNil)
+	replacement, err := pool.Get(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(replacement, Not(Equals), gerrit)
+	c.Assert(pool.Close(), IsNil)
+	c.Assert(counter.count(), Equals, 2)
+}
+
+func (s *PoolTest) TestNewPool_negativeSize(c *C) {
+	counter := &poolCounter{mtx: &sync.Mutex{}}
+	_, err := newPool(-1, nil, counter.new)
+	c.Assert(err, Equals, ErrPoolSizeNegative)
+	c.Assert(counter.count(), Equals, 0)
+}
+
+func (s *PoolTest) TestPool_Close(c *C) {
+	counter := &poolCounter{mtx: &sync.Mutex{}}
+	pool, err := newPool(1, nil, counter.new)
+	c.Assert(err, IsNil)
+	c.Assert(pool.Close(), IsNil)
+	_, err = pool.Get(context.Background())
+	c.Assert(err, Equals, ErrPoolClosed)
+}
@@ -0,0 +1,236 @@
+package gerrittest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opalmer/dockertest"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// DefaultProbeTimeout is the timeout used by DefaultProbes().
+	DefaultProbeTimeout = time.Minute * 5
+
+	// DefaultProbeInterval is the poll interval used by DefaultProbes().
+	DefaultProbeInterval = time.Millisecond * 200
+
+	// ErrVersionNotReported is returned by the probe produced by
+	// NewHTTPProbe() if Gerrit responds without a version.
+	ErrVersionNotReported = errors.New("gerrit did not report a version")
+)
+
+// ReadinessProbe is used to determine when a service running inside of
+// the container is ready. Probes are run in parallel when a container
+// starts and each probe is retried every Interval() until it passes or
+// Timeout() has elapsed.
+type ReadinessProbe interface {
+	// Name returns a short name for the probe which is used in
+	// logs and errors.
+	Name() string
+
+	// Timeout is the maximum amount of time to wait for the
+	// probe to pass.
+	Timeout() time.Duration
+
+	// Interval is the amount of time to wait between attempts.
+	Interval() time.Duration
+
+	// Check performs a single attempt. A nil error means the
+	// service is ready.
+	Check(ctx context.Context, http *dockertest.Port, ssh *dockertest.Port) error
+}
+
+// ProbeFunc is a function which may be used as a ReadinessProbe
+// with NewFuncProbe().
+type ProbeFunc func(ctx context.Context, http *dockertest.Port, ssh *dockertest.Port) error
+
+// probeTiming implements the Timeout() and Interval() functions
+// of ReadinessProbe.
+type probeTiming struct {
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// Timeout returns the maximum amount of time to wait for the probe.
+func (p probeTiming) Timeout() time.Duration {
+	return p.timeout
+}
+
+// Interval returns the amount of time between attempts.
+func (p probeTiming) Interval() time.Duration {
+	return p.interval
+}
+
+// address returns host:port for the provided port.
+func address(port *dockertest.Port) string {
+	return net.JoinHostPort(port.Address, strconv.Itoa(int(port.Public)))
+}
+
+type httpProbe struct {
+	probeTiming
+}
+
+func (p *httpProbe) Name() string {
+	return "http"
+}
+
+// Check requests /config/server/version which Gerrit does not answer
+// correctly until it has finished starting.
+func (p *httpProbe) Check(ctx context.Context, port *dockertest.Port, _ *dockertest.Port) error {
+	url := fmt.Sprintf("http://%s/config/server/version", address(port))
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close() // nolint: errcheck
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, response.StatusCode)
+	}
+	body, err := getResponseBody(response)
+	if err != nil {
+		return err
+	}
+	version := ""
+	if err := json.Unmarshal(body, &version); err != nil {
+		return err
+	}
+	if version == "" {
+		return ErrVersionNotReported
+	}
+	return nil
+}
+
+// NewHTTPProbe returns a ReadinessProbe which passes once Gerrit's REST
+// API reports a version at /config/server/version. Unlike a request to /,
+// which may return 200 while Gerrit is still starting, this requires the
+// REST API to be serving requests.
+func NewHTTPProbe(timeout time.Duration, interval time.Duration) ReadinessProbe {
+	return &httpProbe{probeTiming{timeout: timeout, interval: interval}}
+}
+
+type sshProbe struct {
+	probeTiming
+}
+
+func (p *sshProbe) Name() string {
+	return "ssh"
+}
+
+// Check connects to the ssh port and performs a handshake without any
+// credentials. Gerrit's ssh daemon accepts connections before it's able to
+// complete a handshake so only a handshake which fails authentication is
+// considered to be a success.
+func (p *sshProbe) Check(ctx context.Context, _ *dockertest.Port, port *dockertest.Port) error {
+	addr := address(port)
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close() // nolint: errcheck
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	client, _, _, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            "gerrittest-probe",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err == nil {
+		return client.Close()
+	}
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		return nil
+	}
+	return err
+}
+
+// NewSSHProbe returns a ReadinessProbe which passes once Gerrit's ssh
+// daemon has sent its banner and completed a key exchange.
+func NewSSHProbe(timeout time.Duration, interval time.Duration) ReadinessProbe {
+	return &sshProbe{probeTiming{timeout: timeout, interval: interval}}
+}
+
+type funcProbe struct {
+	probeTiming
+	name  string
+	check ProbeFunc
+}
+
+func (p *funcProbe) Name() string {
+	return p.name
+}
+
+func (p *funcProbe) Check(ctx context.Context, http *dockertest.Port, ssh *dockertest.Port) error {
+	return p.check(ctx, http, ssh)
+}
+
+// NewFuncProbe returns a ReadinessProbe which calls check to determine
+// if the container is ready.
+func NewFuncProbe(name string, timeout time.Duration, interval time.Duration, check ProbeFunc) ReadinessProbe {
+	return &funcProbe{
+		probeTiming: probeTiming{timeout: timeout, interval: interval},
+		name:        name,
+		check:       check,
+	}
+}
+
+// DefaultProbes returns the probes NewConfig() uses: NewHTTPProbe() and
+// NewSSHProbe() using DefaultProbeTimeout and DefaultProbeInterval.
+func DefaultProbes() []ReadinessProbe {
+	return []ReadinessProbe{
+		NewHTTPProbe(DefaultProbeTimeout, DefaultProbeInterval),
+		NewSSHProbe(DefaultProbeTimeout, DefaultProbeInterval),
+	}
+}
+
+// waitProbe runs the probe until it passes, the probe's timeout has
+// elapsed or ctx is cancelled.
+func waitProbe(parent context.Context, probe ReadinessProbe, http *dockertest.Port, ssh *dockertest.Port, errs chan error) {
+	logger := log.WithFields(log.Fields{
+		"cmp":   "container",
+		"phase": "probe",
+		"probe": probe.Name(),
+	})
+	logger.WithField("task", "begin").Debug()
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(parent, probe.Timeout())
+	defer cancel()
+	ticker := time.NewTicker(probe.Interval())
+	defer ticker.Stop()
+	for {
+		err := probe.Check(ctx, http, ssh)
+		if err == nil {
+			logger.WithFields(log.Fields{
+				"task":    "end",
+				"elapsed": time.Since(started),
+			}).Debug()
+			errs <- nil
+			return
+		}
+		logger.WithError(err).Debug()
+
+		select {
+		case <-ctx.Done():
+			errs <- fmt.Errorf(
+				"probe %q failed: %s (last error: %s)",
+				probe.Name(), ctx.Err(), err)
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package gerrittest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opalmer/dockertest"
+	"golang.org/x/crypto/ssh"
+	. "gopkg.in/check.v1"
+)
+
+type ProbeTest struct{}
+
+var _ = Suite(&ProbeTest{})
+
+func (s *ProbeTest) port(c *C, listener net.Listener) *dockertest.Port {
+	split := strings.Split(listener.Addr().String(), ":")
+	port, err := strconv.ParseUint(split[1], 10, 16)
+	c.Assert(err, IsNil)
+	return &dockertest.Port{Address: split[0], Public: uint16(port)}
+}
+
+// sshServer starts an ssh server which rejects all authentication
+// attempts, similar to Gerrit when no accounts exist.
+func (s *ProbeTest) sshServer(c *C) net.Listener {
+	key, err := GenerateRSAKey()
+	c.Assert(err, IsNil)
+	signer, err := ssh.NewSignerFromKey(key)
+	c.Assert(err, IsNil)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, errors.New("denied")
+		},
+	}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			ssh.NewServerConn(conn, config) // nolint: errcheck
+			conn.Close()                    // nolint: errcheck
+		}
+	}()
+	return listener
+}
+
+func (s *ProbeTest) Test_waitProbe(c *C) {
+	count := 0
+	probe := NewFuncProbe("test", time.Second*5, time.Millisecond,
+		func(context.Context, *dockertest.Port, *dockertest.Port) error {
+			count++
+			if count < 3 {
+				return errors.New("not ready")
+			}
+			return nil
+		})
+	errs := make(chan error, 1)
+	waitProbe(context.Background(), probe, nil, nil, errs)
+	c.Assert(<-errs, IsNil)
+	c.Assert(count, Equals, 3)
+}
+
+func (s *ProbeTest) Test_waitProbe_timeout(c *C) {
+	probe := NewFuncProbe("test", time.Millisecond*50, time.Millisecond*10,
+		func(context.Context, *dockertest.Port, *dockertest.Port) error {
+			return errors.New("not ready")
+		})
+	errs := make(chan error, 1)
+	waitProbe(context.Background(), probe, nil, nil, errs)
+	c.Assert(<-errs, ErrorMatches,
+		`probe "test" failed: context deadline exceeded \(last error: not ready\)`)
+}
+
+func (s *ProbeTest) Test_waitProbe_contextCancelled(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errs := make(chan error, 1)
+	probe := NewHTTPProbe(time.Minute, time.Millisecond)
+	waitProbe(ctx, probe, &dockertest.Port{Address: "127.0.0.1"}, nil, errs)
+	c.Assert(<-errs, ErrorMatches, `probe "http" failed: context canceled.*`)
+}
+
+func (s *ProbeTest) Test_ping(c *C) {
+	probes := []ReadinessProbe{}
+	for i := 0; i < 3; i++ {
+		probes = append(probes, NewFuncProbe(
+			fmt.Sprintf("probe%d", i), time.Second, time.Millisecond,
+			func(context.Context, *dockertest.Port, *dockertest.Port) error {
+				return nil
+			}))
+	}
+	c.Assert(ping(context.Background(), probes, nil, nil), IsNil)
+}
+
+func (s *ProbeTest) TestHTTPProbe(c *C) {
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.Path, Equals, "/config/server/version")
+		count++
+		if count == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, ")]}'\n\"2.14.5.1\"") // nolint: errcheck
+	}))
+	defer ts.Close()
+	probe := NewHTTPProbe(time.Second, time.Millisecond)
+	port := s.port(c, ts.Listener)
+	c.Assert(probe.Name(), Equals, "http")
+	c.Assert(probe.Check(context.Background(), port, nil), ErrorMatches, ".* returned 503")
+	c.Assert(probe.Check(context.Background(), port, nil), IsNil)
+}
+
+func (s *ProbeTest) TestHTTPProbe_noVersion(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, ")]}'\n\"\"") // nolint: errcheck
+	}))
+	defer ts.Close()
+	probe := NewHTTPProbe(time.Second, time.Millisecond)
+	c.Assert(probe.Check(context.Background(), s.port(c, ts.Listener), nil), Equals, ErrVersionNotReported)
+}
+
+func (s *ProbeTest) TestSSHProbe(c *C) {
+	listener := s.sshServer(c)
+	defer listener.Close() // nolint: errcheck
+	probe := NewSSHProbe(time.Second, time.Millisecond)
+	c.Assert(probe.Name(), Equals, "ssh")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	c.Assert(probe.Check(ctx, nil, s.port(c, listener)), IsNil)
+}
+
+func (s *ProbeTest) TestSSHProbe_notSSH(c *C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer listener.Close() // nolint: errcheck
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close() // nolint: errcheck
+		}
+	}()
+	probe := NewSSHProbe(time.Second, time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	c.Assert(probe.Check(ctx, nil, s.port(c, listener)), NotNil)
+}
+
+func (s *ProbeTest) TestDefaultProbes(c *C) {
+	probes := DefaultProbes()
+	c.Assert(probes, HasLen, 2)
+	for _, probe := range probes {
+		c.Assert(probe.Timeout(), Equals, DefaultProbeTimeout)
+		c.Assert(probe.Interval(), Equals, DefaultProbeInterval)
+	}
+}
@@ -0,0 +1,185 @@
+package gerrittest
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andygrunwald/go-gerrit"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrProjectNotFound is returned by GetProject if the project
+	// does not exist.
+	ErrProjectNotFound = errors.New("project not found")
+
+	// ErrProjectNotProvided is returned by CreateChange and
+	// CreateProject if no project was provided.
+	ErrProjectNotProvided = errors.New("project not provided")
+)
+
+// ProjectOptions contains optional settings for Gerrit.CreateProject().
+type ProjectOptions struct {
+	// Parent is the project to inherit permissions from. Defaults
+	// to All-Projects.
+	Parent string
+
+	// Description is the description of the project.
+	Description string
+
+	// Branches are the branches to create. The first branch becomes
+	// the project's HEAD. Defaults to master. Branches only exist once
+	// they contain a commit, see CreateEmptyCommit.
+	Branches []string
+
+	// CreateEmptyCommit when true creates an empty initial commit on
+	// each branch so changes have a parent to be based on.
+	CreateEmptyCommit bool
+
+	// SubmitType is the project's submit type, for
+	// example SubmitTypeFastForwardOnly. Defaults to Gerrit's default
+	// which is SubmitTypeMergeIfNecessary.
+	SubmitType string
+
+	// Owners contains the names of the groups which own the project.
+	Owners []string
+}
+
+// Project is a handle to a project in Gerrit returned by
+// Gerrit.CreateProject() and Gerrit.GetProject().
+type Project struct {
+	g   *Gerrit
+	log *log.Entry
+
+	// Name is the name of the project.
+	Name string
+}
+
+// newProject returns a *Project for the named project.
+func (g *Gerrit) newProject(name string) *Project {
+	return &Project{
+		g: g,
+		log: g.log.WithFields(log.Fields{
+			"cmp":     "project",
+			"project": name,
+		}),
+		Name: name,
+	}
+}
+
+// Info returns information about the project from Gerrit.
+func (p *Project) Info() (*gerrit.ProjectInfo, error) {
+	client, err := p.g.HTTP.Gerrit()
+	if err != nil {
+		return nil, err
+	}
+	info, _, err := client.Projects.GetProject(p.Name)
+	return info, err
+}
+
+// CreateChange creates a new change in the project which targets
+// branch. See Gerrit.CreateChange().
+func (p *Project) CreateChange(branch string, subject string) (*Change, error) {
+	return p.g.CreateChange(p, branch, subject)
+}
+
+// CreateBranch creates a new branch from revision which may be a branch
+// name or commit. Revision defaults to the project's HEAD.
+func (p *Project) CreateBranch(branch string, revision string) error {
+	p.log.WithFields(log.Fields{
+		"phase":    "create-branch",
+		"branch":   branch,
+		"revision": revision,
+	}).Debug()
+	client, err := p.g.HTTP.Gerrit()
+	if err != nil {
+		return err
+	}
+	_, _, err = client.Projects.CreateBranch(
+		p.Name, url.PathEscape(branch), &gerrit.BranchInput{Revision: revision})
+	return err
+}
+
+// DeleteBranch deletes the branch.
+func (p *Project) DeleteBranch(branch string) error {
+	p.log.WithFields(log.Fields{
+		"phase":  "delete-branch",
+		"branch": branch,
+	}).Debug()
+	client, err := p.g.HTTP.Gerrit()
+	if err != nil {
+		return err
+	}
+	_, err = client.Projects.DeleteBranch(p.Name, url.PathEscape(branch))
+	return err
+}
+
+// PushConfig applies cfg to the project's refs/meta/config.
+// See Gerrit.PushProjectConfig().
+func (p *Project) PushConfig(cfg *ProjectConfig) error {
+	return p.g.PushProjectConfig(p.Name, cfg)
+}
+
+// Grant adds access rules to the project. See Gerrit.Grant().
+func (p *Project) Grant(rules ...*AccessRule) error {
+	return p.g.Grant(p.Name, rules...)
+}
+
+// restSubmitType converts a submit type, such as SubmitTypeCherryPick,
+// to the value the REST API expects, such as CHERRY_PICK.
+func restSubmitType(submitType string) string {
+	return strings.ToUpper(strings.Replace(submitType, " ", "_", -1))
+}
+
+// CreateProject creates a new project and returns a handle to it. opts
+// may be nil.
+func (g *Gerrit) CreateProject(name string, opts *ProjectOptions) (*Project, error) {
+	if name == "" {
+		return nil, ErrProjectNotProvided
+	}
+	if opts == nil {
+		opts = &ProjectOptions{}
+	}
+	g.log.WithFields(log.Fields{
+		"phase":   "create-project",
+		"project": name,
+	}).Debug()
+	client, err := g.HTTP.Gerrit()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := client.Projects.CreateProject(name, &gerrit.ProjectInput{
+		Parent:            opts.Parent,
+		Description:       opts.Description,
+		Branches:          opts.Branches,
+		CreateEmptyCommit: opts.CreateEmptyCommit,
+		SubmitType:        restSubmitType(opts.SubmitType),
+		Owners:            opts.Owners,
+	}); err != nil {
+		return nil, err
+	}
+	return g.newProject(name), nil
+}
+
+// GetProject returns a handle to an existing project. ErrProjectNotFound
+// is returned if the project does not exist.
+func (g *Gerrit) GetProject(name string) (*Project, error) {
+	if name == "" {
+		return nil, ErrProjectNotProvided
+	}
+	client, err := g.HTTP.Gerrit()
+	if err != nil {
+		return nil, err
+	}
+	_, response, err := client.Projects.GetProject(name)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return nil, ErrProjectNotFound
+		}
+		return nil, err
+	}
+	return g.newProject(name), nil
+}
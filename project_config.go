@@ -0,0 +1,520 @@
+package gerrittest
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-ini/ini"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SubmitTypeMergeIfNecessary creates a merge commit only when the
+	// change can't be fast forwarded. This is Gerrit's default.
+	SubmitTypeMergeIfNecessary = "merge if necessary"
+
+	// SubmitTypeFastForwardOnly only submits changes which can be
+	// fast forwarded.
+	SubmitTypeFastForwardOnly = "fast forward only"
+
+	// SubmitTypeRebaseIfNecessary rebases the change when it can't
+	// be fast forwarded.
+	SubmitTypeRebaseIfNecessary = "rebase if necessary"
+
+	// SubmitTypeRebaseAlways always rebases the change.
+	SubmitTypeRebaseAlways = "rebase always"
+
+	// SubmitTypeMergeAlways always creates a merge commit.
+	SubmitTypeMergeAlways = "merge always"
+
+	// SubmitTypeCherryPick cherry picks the change onto the branch.
+	SubmitTypeCherryPick = "cherry pick"
+)
+
+const (
+	// LabelFunctionMaxWithBlock requires the label's maximum value and
+	// blocks submission if the minimum value is present.
+	LabelFunctionMaxWithBlock = "MaxWithBlock"
+
+	// LabelFunctionAnyWithBlock blocks submission if the minimum value
+	// is present but does not require any other value.
+	LabelFunctionAnyWithBlock = "AnyWithBlock"
+
+	// LabelFunctionMaxNoBlock requires the label's maximum value but
+	// the minimum value does not block submission.
+	LabelFunctionMaxNoBlock = "MaxNoBlock"
+
+	// LabelFunctionNoBlock never blocks or requires a value.
+	LabelFunctionNoBlock = "NoBlock"
+
+	// LabelFunctionNoOp is the same as LabelFunctionNoBlock.
+	LabelFunctionNoOp = "NoOp"
+
+	// LabelFunctionPatchSetLock prevents new patch sets from being
+	// uploaded while the label's maximum value is present.
+	LabelFunctionPatchSetLock = "PatchSetLock"
+)
+
+const (
+	capability = "capability"
+
+	// groupsFile is the name of the file in refs/meta/config which maps
+	// group UUIDs to the names used by project.config.
+	groupsFile = "groups"
+)
+
+// LabelValue is a single value which may be voted on a label.
+type LabelValue struct {
+	// Value is the numeric value, for example -1 or 2.
+	Value int `json:"value"`
+
+	// Description describes the value, for example "Looks good to me".
+	Description string `json:"description"`
+}
+
+// String returns the value as it's written to project.config.
+func (v LabelValue) String() string {
+	if v.Value > 0 {
+		return fmt.Sprintf("+%d %s", v.Value, v.Description)
+	}
+	return fmt.Sprintf("%d %s", v.Value, v.Description)
+}
+
+// Label describes a review label such as Code-Review or Verified.
+type Label struct {
+	// Name is the name of the label.
+	Name string `json:"name"`
+
+	// Function determines how votes on the label affect submission,
+	// for example LabelFunctionMaxWithBlock.
+	Function string `json:"function"`
+
+	// Values contains the values which may be voted.
+	Values []LabelValue `json:"values"`
+
+	// DefaultValue is the value preselected when voting.
+	DefaultValue int `json:"default_value"`
+
+	// CopyMinScore copies the minimum score to new patch sets.
+	CopyMinScore bool `json:"copy_min_score"`
+
+	// CopyMaxScore copies the maximum score to new patch sets.
+	CopyMaxScore bool `json:"copy_max_score"`
+
+	// CopyAllScoresOnTrivialRebase copies all scores to new patch
+	// sets which are trivial rebases of the previous patch set.
+	CopyAllScoresOnTrivialRebase bool `json:"copy_all_scores_on_trivial_rebase"`
+
+	// CopyAllScoresIfNoCodeChange copies all scores to new patch sets
+	// which only change the commit message.
+	CopyAllScoresIfNoCodeChange bool `json:"copy_all_scores_if_no_code_change"`
+
+	// Groups contains the names of the groups allowed to vote on the
+	// label on refs/heads/*. Groups are only used when the label is
+	// applied from Config.Labels, ProjectConfig.SetLabel() ignores them.
+	// The groups must exist when Gerrit is setup, such as Administrators
+	// or Registered Users. Use Gerrit.Grant() for groups created later.
+	Groups []string `json:"groups"`
+}
+
+// valueRange returns the range of values, for example -1..+1, used
+// when granting permission to vote on the label.
+func (l *Label) valueRange() string {
+	min, max := l.Values[0].Value, l.Values[0].Value
+	for _, value := range l.Values {
+		if value.Value < min {
+			min = value.Value
+		}
+		if value.Value > max {
+			max = value.Value
+		}
+	}
+	return fmt.Sprintf("%+d..%+d", min, max)
+}
+
+// DefaultLabels returns the labels NewConfig() uses which adds the
+// Verified label. Administrators and project owners may vote on it.
+func DefaultLabels() []*Label {
+	return []*Label{{
+		Name:         VerifiedLabel,
+		Function:     LabelFunctionMaxWithBlock,
+		DefaultValue: 0,
+		Values: []LabelValue{
+			{Value: -1, Description: "Fails"},
+			{Value: 0, Description: "No Score"},
+			{Value: 1, Description: "Verified"},
+		},
+		Groups: []string{"Administrators", "Project Owners"},
+	}}
+}
+
+// validate returns an error if the label can't be written.
+func (l *Label) validate() error {
+	if l.Name == "" {
+		return errors.New("label: name not provided")
+	}
+	if len(l.Values) == 0 {
+		return fmt.Errorf("label %s: values not provided", l.Name)
+	}
+	return nil
+}
+
+// projectConfigFile is used to edit a project.config file on disk.
+type projectConfigFile struct {
+	log *log.Entry
+	ini *ini.File
+}
+
+// set replaces the values of key in section.
+func (c *projectConfigFile) set(section string, key string, values ...string) {
+	iniSection := c.ini.Section(section)
+	iniSection.DeleteKey(key)
+	if len(values) == 0 {
+		return
+	}
+	iniKey := iniSection.Key(key)
+	iniKey.SetValue(values[0])
+	for _, value := range values[1:] {
+		iniKey.AddShadow(value) // nolint: errcheck
+	}
+}
+
+// setLabel replaces the label's section.
+func (c *projectConfigFile) setLabel(label *Label) {
+	c.log.WithFields(log.Fields{
+		"phase": "set-label",
+		"label": label.Name,
+	}).Debug()
+
+	section := fmt.Sprintf("label %q", label.Name)
+	c.ini.DeleteSection(section)
+	if label.Function != "" {
+		c.set(section, "function", label.Function)
+	}
+	c.set(section, "defaultValue", strconv.Itoa(label.DefaultValue))
+	values := []string{}
+	for _, value := range label.Values {
+		values = append(values, value.String())
+	}
+	c.set(section, "value", values...)
+	for _, option := range []struct {
+		key     string
+		enabled bool
+	}{
+		{"copyMinScore", label.CopyMinScore},
+		{"copyMaxScore", label.CopyMaxScore},
+		{"copyAllScoresOnTrivialRebase", label.CopyAllScoresOnTrivialRebase},
+		{"copyAllScoresIfNoCodeChange", label.CopyAllScoresIfNoCodeChange},
+	} {
+		if option.enabled {
+			c.set(section, option.key, "true")
+		}
+	}
+}
+
+// grant adds a rule granting permission to the named group in section
+// unless the rule is already present. valueRange is only used for label
+// permissions and may be empty. The group must also be listed in the
+// project's groups file.
+func (c *projectConfigFile) grant(section string, permission string, valueRange string, group string) {
+	rule := "group " + group
+	if valueRange != "" {
+		rule = valueRange + " " + rule
+	}
+	iniSection := c.ini.Section(section)
+	if !iniSection.HasKey(permission) {
+		iniSection.Key(permission).SetValue(rule)
+		return
+	}
+	key := iniSection.Key(permission)
+	for _, existing := range key.ValueWithShadows() {
+		if existing == rule {
+			return
+		}
+	}
+	key.AddShadow(rule) // nolint: errcheck
+}
+
+// save writes the file to path.
+func (c *projectConfigFile) save(path string) error {
+	c.log.WithField("phase", "write").Debug()
+	return c.ini.SaveTo(path)
+}
+
+// newProjectConfigFile loads the project.config file at path. If the
+// file does not exist an empty file is returned.
+func newProjectConfigFile(path string) (*projectConfigFile, error) {
+	logger := log.WithField("cmp", "project-config")
+	options := ini.LoadOptions{AllowShadows: true}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		logger.WithField("action", "new").Debug()
+		cfg, err := ini.LoadSources(options, []byte(""))
+		if err != nil {
+			return nil, err
+		}
+		return &projectConfigFile{log: logger, ini: cfg}, nil
+	}
+
+	logger = logger.WithField("path", path)
+	logger.WithField("action", "load").Debug()
+	cfg, err := ini.LoadSources(options, path)
+	if err != nil {
+		return nil, err
+	}
+	return &projectConfigFile{log: logger, ini: cfg}, nil
+}
+
+// readGroupsFile returns a map of group names to UUIDs from the groups
+// file at path. An empty map is returned if the file does not exist.
+func readGroupsFile(path string) (map[string]string, error) {
+	groups := map[string]string{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return groups, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		split := strings.SplitN(line, "\t", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("%s: invalid line %q", path, line)
+		}
+		groups[strings.TrimSpace(split[1])] = strings.TrimSpace(split[0])
+	}
+	return groups, nil
+}
+
+// writeGroupsFile writes groups, a map of group names to UUIDs, to path
+// in the format Gerrit expects.
+func writeGroupsFile(path string, groups map[string]string) error {
+	names := []string{}
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return groups[names[i]] < groups[names[j]]
+	})
+	lines := []string{"# UUID\tGroup Name", "#"}
+	for _, name := range names {
+		lines = append(lines, groups[name]+"\t"+name)
+	}
+	return ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// ProjectConfig is used to build changes to a project's project.config
+// which can then be pushed using Gerrit.PushProjectConfig(). Each method
+// records an edit and returns the *ProjectConfig so calls may be chained.
+// Edits are applied, in order, on top of the project's existing
+// configuration. Groups are referred to by name and are added to the
+// project's groups file when the config is pushed.
+type ProjectConfig struct {
+	edits  []func(*projectConfigFile)
+	groups []string
+	err    error
+}
+
+// edit records an edit to apply to the file.
+func (c *ProjectConfig) edit(fn func(*projectConfigFile)) *ProjectConfig {
+	c.edits = append(c.edits, fn)
+	return c
+}
+
+// Set replaces the values of key in section, for example
+// Set(`access "refs/*"`, "exclusiveGroupPermissions", "read"). Providing
+// no values removes the key.
+func (c *ProjectConfig) Set(section string, key string, values ...string) *ProjectConfig {
+	return c.edit(func(file *projectConfigFile) {
+		file.set(section, key, values...)
+	})
+}
+
+// SetLabel adds the label or replaces the existing label with the
+// same name.
+func (c *ProjectConfig) SetLabel(label *Label) *ProjectConfig {
+	if err := label.validate(); err != nil && c.err == nil {
+		c.err = err
+	}
+	return c.edit(func(file *projectConfigFile) {
+		file.setLabel(label)
+	})
+}
+
+// Grant adds access rules. Rules which are already present are
+// not duplicated.
+func (c *ProjectConfig) Grant(rules ...*AccessRule) *ProjectConfig {
+	for _, rule := range rules {
+		rule := rule
+		if err := rule.validate(); err != nil {
+			if c.err == nil {
+				c.err = err
+			}
+			continue
+		}
+		c.groups = append(c.groups, rule.Group)
+		c.edit(func(file *projectConfigFile) {
+			file.grant(
+				fmt.Sprintf("access %q", rule.Ref), rule.Permission,
+				rule.Range, rule.Group)
+		})
+	}
+	return c
+}
+
+// GrantCapability grants a global capability, such as accessDatabase,
+// to the named group. Capabilities only have an effect on All-Projects.
+func (c *ProjectConfig) GrantCapability(name string, group string) *ProjectConfig {
+	c.groups = append(c.groups, group)
+	return c.edit(func(file *projectConfigFile) {
+		file.grant(capability, name, "", group)
+	})
+}
+
+// SetSubmitType sets the project's submit type, for
+// example SubmitTypeFastForwardOnly.
+func (c *ProjectConfig) SetSubmitType(submitType string) *ProjectConfig {
+	return c.Set("submit", "action", submitType)
+}
+
+// SetReceiveOption sets an option in the receive section, for
+// example SetReceiveOption("requireChangeId", "true").
+func (c *ProjectConfig) SetReceiveOption(key string, value string) *ProjectConfig {
+	return c.Set("receive", key, value)
+}
+
+// SetPluginConfig sets the values of key in the named plugin's section.
+func (c *ProjectConfig) SetPluginConfig(plugin string, key string, values ...string) *ProjectConfig {
+	return c.Set(fmt.Sprintf("plugin %q", plugin), key, values...)
+}
+
+// write applies the edits to the project.config file at path.
+func (c *ProjectConfig) write(path string) error {
+	if c.err != nil {
+		return c.err
+	}
+	file, err := newProjectConfigFile(path)
+	if err != nil {
+		return err
+	}
+	for _, edit := range c.edits {
+		edit(file)
+	}
+	return file.save(path)
+}
+
+// NewProjectConfig returns an empty *ProjectConfig.
+func NewProjectConfig() *ProjectConfig {
+	return &ProjectConfig{}
+}
+
+// setupProjectConfig returns the *ProjectConfig pushed to All-Projects
+// during setup which adds the labels and grants the groups in each
+// label permission to vote on refs/heads/*. Access Database is granted
+// because it's required to run 'gerrit gsql' which Gerrit.Reset()
+// relies on.
+func setupProjectConfig(labels []*Label) *ProjectConfig {
+	cfg := NewProjectConfig()
+	for _, label := range labels {
+		cfg.SetLabel(label)
+		if label.validate() != nil {
+			continue
+		}
+		for _, group := range label.Groups {
+			cfg.Grant(&AccessRule{
+				Ref:        "refs/heads/*",
+				Permission: "label-" + label.Name,
+				Range:      label.valueRange(),
+				Group:      group,
+			})
+		}
+	}
+	return cfg.GrantCapability("accessDatabase", "Administrators")
+}
+
+// updateProjectConfig checks out refs/meta/config for the project, applies
+// cfg and pushes the result. Any groups cfg refers to which are not in the
+// project's groups file are looked up and added. The caller is
+// responsible for destroying the returned repository.
+func (g *Gerrit) updateProjectConfig(project string, cfg *ProjectConfig, message string) (*Repository, error) { // nolint: gocyclo
+	logger := g.log.WithFields(log.Fields{
+		"phase":   "update-project-config",
+		"project": project,
+	})
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+
+	repo, err := g.checkoutConfig(project)
+	if err != nil {
+		return nil, err
+	}
+	configPath := filepath.Join(repo.Root, "project.config")
+	groupsPath := filepath.Join(repo.Root, groupsFile)
+	paths := []string{configPath}
+
+	groups, err := readGroupsFile(groupsPath)
+	if err != nil {
+		repo.Destroy() // nolint: errcheck
+		return nil, err
+	}
+	added := false
+	for _, name := range cfg.groups {
+		if _, found := groups[name]; found {
+			continue
+		}
+		logger.WithFields(log.Fields{
+			"action": "lookup-group",
+			"group":  name,
+		}).Debug()
+		group, err := g.GetGroup(name)
+		if err != nil {
+			repo.Destroy() // nolint: errcheck
+			return nil, err
+		}
+		groups[name] = group.UUID
+		added = true
+	}
+	if added {
+		if err := writeGroupsFile(groupsPath, groups); err != nil {
+			repo.Destroy() // nolint: errcheck
+			return nil, err
+		}
+		paths = append(paths, groupsPath)
+	}
+
+	logger.WithField("action", "write").Debug()
+	if err := cfg.write(configPath); err != nil {
+		repo.Destroy() // nolint: errcheck
+		return nil, err
+	}
+	if _, _, err := repo.Git(append(DefaultGitCommands["add"], paths...)); err != nil {
+		repo.Destroy() // nolint: errcheck
+		return nil, err
+	}
+	if err := g.pushConfigChanges(repo, message); err != nil {
+		repo.Destroy() // nolint: errcheck
+		return nil, err
+	}
+	return repo, nil
+}
+
+// PushProjectConfig applies cfg to the project's refs/meta/config and
+// pushes the result. Nothing is pushed if cfg does not change the
+// project's configuration.
+func (g *Gerrit) PushProjectConfig(project string, cfg *ProjectConfig) error {
+	repo, err := g.updateProjectConfig(project, cfg, "Update project config")
+	if err != nil {
+		return err
+	}
+	return repo.Destroy()
+}
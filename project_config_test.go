@@ -0,0 +1,266 @@
+package gerrittest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-ini/ini"
+	. "gopkg.in/check.v1"
+)
+
+const (
+	accessHeads   = `access "refs/heads/*"`
+	labelVerified = `label "Verified"`
+)
+
+type ProjectConfigTest struct{}
+
+var _ = Suite(&ProjectConfigTest{})
+
+func (s *ProjectConfigTest) testDefaultConfig(c *C, path string) {
+	cfg, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, path)
+	c.Assert(err, IsNil)
+	heads := cfg.Section(accessHeads)
+	c.Assert(
+		heads.Key("label-Verified").ValueWithShadows(), DeepEquals,
+		[]string{"-1..+1 group Administrators", "-1..+1 group Project Owners"})
+	verifiedLabel := cfg.Section(labelVerified)
+	c.Assert(verifiedLabel.Key("function").Value(), Equals, "MaxWithBlock")
+	c.Assert(verifiedLabel.Key("defaultValue").Value(), Equals, "0")
+	c.Assert(
+		verifiedLabel.Key("value").ValueWithShadows(), DeepEquals,
+		[]string{"-1 Fails", "0 No Score", "+1 Verified"})
+	c.Assert(
+		cfg.Section(capability).Key("accessDatabase").Value(), Equals,
+		"group Administrators")
+}
+
+func (s *ProjectConfigTest) TestSetupProjectConfig_missingFile(c *C) {
+	file, err := ioutil.TempFile("", "")
+	defer os.Remove(file.Name()) // nolint: errcheck
+	c.Assert(err, IsNil)
+	c.Assert(file.Close(), IsNil)
+	c.Assert(os.Remove(file.Name()), IsNil)
+	c.Assert(setupProjectConfig(DefaultLabels()).write(file.Name()), IsNil)
+	s.testDefaultConfig(c, file.Name())
+}
+
+func (s *ProjectConfigTest) TestSetupProjectConfig_existingConfig(c *C) {
+	file, err := ioutil.TempFile("", "")
+	c.Assert(err, IsNil)
+	_, err = file.WriteString(`
+[label "Verified"]
+default = 1
+	`)
+	c.Assert(err, IsNil)
+	defer os.Remove(file.Name()) // nolint: errcheck
+	c.Assert(err, IsNil)
+	c.Assert(file.Close(), IsNil)
+	c.Assert(setupProjectConfig(DefaultLabels()).write(file.Name()), IsNil)
+	s.testDefaultConfig(c, file.Name())
+}
+
+func (s *ProjectConfigTest) TestGroupsFile(c *C) {
+	path := filepath.Join(c.MkDir(), groupsFile)
+	groups, err := readGroupsFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(groups, HasLen, 0)
+
+	c.Assert(writeGroupsFile(path, map[string]string{
+		"Registered Users": "global:Registered-Users",
+		"Administrators":   "53a4f647a89ea57992571187d8025f830625192a",
+	}), IsNil)
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals,
+		"# UUID\tGroup Name\n#\n"+
+			"53a4f647a89ea57992571187d8025f830625192a\tAdministrators\n"+
+			"global:Registered-Users\tRegistered Users\n")
+	groups, err = readGroupsFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(groups, DeepEquals, map[string]string{
+		"Registered Users": "global:Registered-Users",
+		"Administrators":   "53a4f647a89ea57992571187d8025f830625192a",
+	})
+}
+
+func (s *ProjectConfigTest) TestGroupsFile_invalid(c *C) {
+	path := filepath.Join(c.MkDir(), groupsFile)
+	c.Assert(ioutil.WriteFile(path, []byte("foo\n"), 0600), IsNil)
+	_, err := readGroupsFile(path)
+	c.Assert(err, ErrorMatches, `.*: invalid line "foo"`)
+}
+
+func (s *ProjectConfigTest) Test_projectConfigFile_grant(c *C) {
+	path := filepath.Join(c.MkDir(), "project.config")
+	cfg, err := newProjectConfigFile(path)
+	c.Assert(err, IsNil)
+	cfg.grant(accessHeads, "label-Verified", "-1..+1", "CI")
+	cfg.grant(accessHeads, "label-Verified", "-1..+1", "CI")
+	cfg.grant(accessHeads, "label-Verified", "-1..+1", "QA")
+	cfg.grant(`access "refs/*"`, "read", "", "Registered Users")
+	c.Assert(cfg.ini.SaveTo(path), IsNil)
+
+	written, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, path)
+	c.Assert(err, IsNil)
+	c.Assert(
+		written.Section(accessHeads).Key("label-Verified").ValueWithShadows(),
+		DeepEquals, []string{"-1..+1 group CI", "-1..+1 group QA"})
+	c.Assert(
+		written.Section(`access "refs/*"`).Key("read").Value(), Equals,
+		"group Registered Users")
+}
+
+func (s *ProjectConfigTest) TestLabelValue_String(c *C) {
+	c.Assert(LabelValue{Value: -2, Description: "No"}.String(), Equals, "-2 No")
+	c.Assert(LabelValue{Value: 0, Description: "No Score"}.String(), Equals, "0 No Score")
+	c.Assert(LabelValue{Value: 2, Description: "Yes"}.String(), Equals, "+2 Yes")
+}
+
+func (s *ProjectConfigTest) TestProjectConfig_write(c *C) {
+	path := filepath.Join(c.MkDir(), "project.config")
+	c.Assert(ioutil.WriteFile(path, []byte(`
+[label "Lint"]
+	function = NoBlock
+	copyMinScore = true
+[receive]
+	requireChangeId = true
+`), 0600), IsNil)
+
+	c.Assert(NewProjectConfig().
+		SetLabel(&Label{
+			Name:     "Lint",
+			Function: LabelFunctionMaxWithBlock,
+			Values: []LabelValue{
+				{Value: -1, Description: "Fails"},
+				{Value: 0, Description: "No Score"},
+				{Value: 1, Description: "Passes"},
+			},
+			CopyAllScoresIfNoCodeChange: true,
+		}).
+		Grant(&AccessRule{
+			Ref: "refs/heads/*", Permission: "label-Lint", Range: "-1..+1", Group: "CI"}).
+		SetSubmitType(SubmitTypeFastForwardOnly).
+		SetReceiveOption("requireSignedOffBy", "true").
+		SetPluginConfig("reviewers", "reviewer", "jdoe", "CI").
+		write(path), IsNil)
+
+	cfg, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, path)
+	c.Assert(err, IsNil)
+	label := cfg.Section(`label "Lint"`)
+	c.Assert(label.KeyStrings(), DeepEquals, []string{
+		"function", "defaultValue", "value", "copyAllScoresIfNoCodeChange"})
+	c.Assert(label.Key("function").Value(), Equals, "MaxWithBlock")
+	c.Assert(
+		label.Key("value").ValueWithShadows(), DeepEquals,
+		[]string{"-1 Fails", "0 No Score", "+1 Passes"})
+	c.Assert(
+		cfg.Section(accessHeads).Key("label-Lint").Value(), Equals, "-1..+1 group CI")
+	c.Assert(cfg.Section("submit").Key("action").Value(), Equals, "fast forward only")
+	c.Assert(cfg.Section("receive").Key("requireChangeId").Value(), Equals, "true")
+	c.Assert(cfg.Section("receive").Key("requireSignedOffBy").Value(), Equals, "true")
+	c.Assert(
+		cfg.Section(`plugin "reviewers"`).Key("reviewer").ValueWithShadows(),
+		DeepEquals, []string{"jdoe", "CI"})
+}
+
+func (s *ProjectConfigTest) TestProjectConfig_Set_remove(c *C) {
+	path := filepath.Join(c.MkDir(), "project.config")
+	c.Assert(ioutil.WriteFile(path, []byte("[submit]\n\taction = cherry pick\n"), 0600), IsNil)
+	c.Assert(NewProjectConfig().Set("submit", "action").write(path), IsNil)
+	cfg, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, path)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Section("submit").HasKey("action"), Equals, false)
+}
+
+func (s *ProjectConfigTest) TestProjectConfig_errors(c *C) {
+	path := filepath.Join(c.MkDir(), "project.config")
+	c.Assert(
+		NewProjectConfig().SetLabel(&Label{}).write(path), ErrorMatches,
+		"label: name not provided")
+	c.Assert(
+		NewProjectConfig().SetLabel(&Label{Name: "Lint"}).write(path), ErrorMatches,
+		"label Lint: values not provided")
+	c.Assert(
+		NewProjectConfig().Grant(&AccessRule{Ref: "refs/*"}).write(path), ErrorMatches,
+		"access rule: permission not provided")
+}
+
+func (s *ProjectConfigTest) TestProjectConfig_groups(c *C) {
+	cfg := NewProjectConfig().
+		Grant(&AccessRule{Ref: "refs/*", Permission: "read", Group: "CI"}).
+		GrantCapability("createProject", "Release Managers")
+	c.Assert(cfg.groups, DeepEquals, []string{"CI", "Release Managers"})
+}
+
+func (s *ProjectConfigTest) TestPushProjectConfig(c *C) {
+	if testing.Short() {
+		c.Skip("-short provided")
+	}
+	g, err := New(NewConfig())
+	c.Assert(err, IsNil)
+	defer g.Destroy() // nolint: errcheck
+
+	cfg := NewProjectConfig().
+		SetSubmitType(SubmitTypeCherryPick).
+		SetReceiveOption("requireChangeId", "true")
+	c.Assert(g.PushProjectConfig("All-Projects", cfg), IsNil)
+
+	client, err := g.HTTP.Gerrit()
+	c.Assert(err, IsNil)
+	info, _, err := client.Projects.GetConfig("All-Projects")
+	c.Assert(err, IsNil)
+	c.Assert(info.SubmitType, Equals, "CHERRY_PICK")
+}
+
+func (s *ProjectConfigTest) TestLabel_valueRange(c *C) {
+	label := &Label{Values: []LabelValue{{Value: 0}, {Value: 2}, {Value: -2}, {Value: 1}}}
+	c.Assert(label.valueRange(), Equals, "-2..+2")
+	label = &Label{Values: []LabelValue{{Value: 0}, {Value: 1}}}
+	c.Assert(label.valueRange(), Equals, "+0..+1")
+}
+
+func (s *ProjectConfigTest) TestSetupProjectConfig_labels(c *C) {
+	path := filepath.Join(c.MkDir(), "project.config")
+	cfg := setupProjectConfig([]*Label{
+		{
+			Name:     "Lint",
+			Function: LabelFunctionNoBlock,
+			Values:   []LabelValue{{Value: -1, Description: "Fails"}, {Value: 1, Description: "Passes"}},
+			Groups:   []string{"CI"},
+		},
+		{
+			Name:         "QA-Review",
+			Function:     LabelFunctionMaxWithBlock,
+			Values:       []LabelValue{{Value: -2, Description: "No"}, {Value: 2, Description: "Yes"}},
+			CopyMinScore: true,
+			Groups:       []string{"QA", "Administrators"},
+		},
+	})
+	c.Assert(cfg.groups, DeepEquals, []string{"CI", "QA", "Administrators", "Administrators"})
+	c.Assert(cfg.write(path), IsNil)
+
+	written, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, path)
+	c.Assert(err, IsNil)
+	_, err = written.GetSection(labelVerified)
+	c.Assert(err, NotNil)
+	c.Assert(written.Section(`label "Lint"`).Key("function").Value(), Equals, "NoBlock")
+	c.Assert(written.Section(`label "QA-Review"`).Key("copyMinScore").Value(), Equals, "true")
+	heads := written.Section(accessHeads)
+	c.Assert(heads.Key("label-Lint").ValueWithShadows(), DeepEquals, []string{"-1..+1 group CI"})
+	c.Assert(
+		heads.Key("label-QA-Review").ValueWithShadows(), DeepEquals,
+		[]string{"-2..+2 group QA", "-2..+2 group Administrators"})
+	c.Assert(
+		written.Section(capability).Key("accessDatabase").Value(), Equals,
+		"group Administrators")
+}
+
+func (s *ProjectConfigTest) TestSetupProjectConfig_invalidLabel(c *C) {
+	path := filepath.Join(c.MkDir(), "project.config")
+	c.Assert(
+		setupProjectConfig([]*Label{{Name: "Lint", Groups: []string{"CI"}}}).write(path),
+		ErrorMatches, "label Lint: values not provided")
+}
@@ -0,0 +1,104 @@
+package gerrittest
+
+import (
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type ProjectTest struct{}
+
+var _ = Suite(&ProjectTest{})
+
+func (s *ProjectTest) Test_restSubmitType(c *C) {
+	c.Assert(restSubmitType(""), Equals, "")
+	c.Assert(restSubmitType(SubmitTypeMergeIfNecessary), Equals, "MERGE_IF_NECESSARY")
+	c.Assert(restSubmitType(SubmitTypeFastForwardOnly), Equals, "FAST_FORWARD_ONLY")
+	c.Assert(restSubmitType(SubmitTypeCherryPick), Equals, "CHERRY_PICK")
+}
+
+func (s *ProjectTest) TestNotProvided(c *C) {
+	g := &Gerrit{}
+	_, err := g.CreateProject("", nil)
+	c.Assert(err, Equals, ErrProjectNotProvided)
+	_, err = g.GetProject("")
+	c.Assert(err, Equals, ErrProjectNotProvided)
+	_, err = g.CreateChange(nil, "", "subject")
+	c.Assert(err, Equals, ErrProjectNotProvided)
+}
+
+func (s *ProjectTest) TestCreateProject(c *C) {
+	if testing.Short() {
+		c.Skip("-short provided")
+	}
+	g, err := New(NewConfig())
+	c.Assert(err, IsNil)
+	defer g.Destroy() // nolint: errcheck
+
+	name := generaRandomString(16)
+	_, err = g.GetProject(name)
+	c.Assert(err, Equals, ErrProjectNotFound)
+
+	project, err := g.CreateProject(name, &ProjectOptions{
+		Description:       "testing",
+		Branches:          []string{"master", "release"},
+		CreateEmptyCommit: true,
+		SubmitType:        SubmitTypeFastForwardOnly,
+		Owners:            []string{"Administrators"},
+	})
+	c.Assert(err, IsNil)
+	info, err := project.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.Description, Equals, "testing")
+
+	found, err := g.GetProject(name)
+	c.Assert(err, IsNil)
+	c.Assert(found.Name, Equals, name)
+
+	client, err := g.HTTP.Gerrit()
+	c.Assert(err, IsNil)
+	config, _, err := client.Projects.GetConfig(name)
+	c.Assert(err, IsNil)
+	c.Assert(config.SubmitType, Equals, "FAST_FORWARD_ONLY")
+	_, _, err = client.Projects.GetBranch(name, "release")
+	c.Assert(err, IsNil)
+
+	change, err := project.CreateChange("", "Add feature")
+	c.Assert(err, IsNil)
+	defer change.Destroy() // nolint: errcheck
+}
+
+func (s *ProjectTest) TestBranches(c *C) {
+	if testing.Short() {
+		c.Skip("-short provided")
+	}
+	g, err := New(NewConfig())
+	c.Assert(err, IsNil)
+	defer g.Destroy() // nolint: errcheck
+
+	project, err := g.CreateProject(generaRandomString(16), &ProjectOptions{
+		CreateEmptyCommit: true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(project.CreateBranch("release/1.0", "master"), IsNil)
+
+	// The change should be based on the tip of the release branch
+	// and pushed for review on it.
+	change, err := project.CreateChange("release/1.0", "Fix bug")
+	c.Assert(err, IsNil)
+	defer change.Destroy() // nolint: errcheck
+	c.Assert(change.Add("README", 0600, "fixed"), IsNil)
+	c.Assert(change.Push(), IsNil)
+	info, err := change.Info()
+	c.Assert(err, IsNil)
+	c.Assert(info.Branch, Equals, "release/1.0")
+	parents, _, err := change.Repo.Git([]string{"rev-list", "--count", "HEAD"})
+	c.Assert(err, IsNil)
+	c.Assert(strings.TrimSpace(parents), Equals, "2")
+
+	c.Assert(project.DeleteBranch("release/1.0"), IsNil)
+	c.Assert(project.DeleteBranch("release/1.0"), NotNil)
+	_, err = project.CreateChange("release/1.0", "Fix bug")
+	c.Assert(err, Equals, ErrBranchDoesNotExist)
+}
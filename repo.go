@@ -33,6 +33,8 @@ var (
 		"push":                {"push", "--porcelain"},
 		"last-commit-message": {"log", "-n", "1", "--format=medium"},
 		"amend":               {"commit", "--amend", "--no-edit", "--allow-empty"},
+		"fetch":               {"fetch", "--quiet", "origin"},
+		"checkout":            {"checkout", "--quiet"},
 	}
 
 	// ErrRemoteDoesNotExist is returned by GetRemote if the requested
@@ -48,6 +50,10 @@ var (
 	ErrRemoteDiffers = errors.New(
 		"the requested remote exists but with a different url")
 
+	// ErrBranchDoesNotExist is returned by Checkout if the requested
+	// branch does not exist in origin.
+	ErrBranchDoesNotExist = errors.New("requested branch does not exist")
+
 	// ErrNoCommits is returned by ChangeID if there are not any commits
 	// to the repository yet.
 	ErrNoCommits = errors.New("no commits")
@@ -64,6 +70,10 @@ type Repository struct {
 	SSHCommand string
 	Root       string
 	Username   string
+
+	// Branch is the branch set by Checkout(). Push() will push to
+	// this branch for review if no ref is provided. Defaults to master.
+	Branch string
 }
 
 // setEnvironment sets up the environment for the given command.
@@ -84,15 +94,11 @@ func (r *Repository) setEnvironment(cmd *exec.Cmd) error {
 }
 
 func (r *Repository) run(cmd *exec.Cmd) (string, string, error) {
-	cwd, err := os.Getwd()
 	logger := r.log.WithFields(log.Fields{
 		"phase": "run",
 		"cmd":   strings.Join(cmd.Args, " "),
-		"wd":    cwd,
+		"wd":    cmd.Dir,
 	})
-	if err != nil {
-		return "", "", err
-	}
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return "", "", err
@@ -133,20 +139,13 @@ func (r *Repository) run(cmd *exec.Cmd) (string, string, error) {
 // Git runs git with the provided arguments. This also ensures the proper
 // working path and environment are set before calling git.
 func (r *Repository) Git(args []string) (string, string, error) {
-	workdir, err := os.Getwd()
-	if err != nil {
-		return "", "", err
-	}
-
-	// Change directories to the directory of the repository. Technically
-	// there's a -C flag but not all versions of git have this flag and not
-	// all subcommands respect it the same way.
-	defer os.Chdir(workdir) // nolint: errcheck
-	if err := os.Chdir(r.Root); err != nil {
-		return "", "", err
-	}
-
+	// Run git from the directory of the repository. Technically there's a
+	// -C flag but not all versions of git have this flag and not all
+	// subcommands respect it the same way. Setting the working directory
+	// of the process, rather than our own, also allows multiple
+	// repositories to be used concurrently.
 	cmd := exec.Command(GitCommand, args...)
+	cmd.Dir = r.Root
 	if err := r.setEnvironment(cmd); err != nil {
 		return "", "", err
 	}
@@ -199,10 +198,14 @@ func (r *Repository) Commit(message string) error {
 }
 
 // Push will push changes to the given remote and reference. `ref`
-// will default to 'HEAD:refs/for/master' if not provided.
+// will default to 'HEAD:refs/for/<Branch>' if not provided.
 func (r *Repository) Push(ref string) error {
 	if ref == "" {
-		ref = "HEAD:refs/for/master"
+		branch := r.Branch
+		if branch == "" {
+			branch = "master"
+		}
+		ref = "HEAD:refs/for/" + branch
 	}
 
 	_, stderr, err := r.Git(append(DefaultGitCommands["push"], "origin", ref))
@@ -215,6 +218,32 @@ func (r *Repository) Push(ref string) error {
 	return err
 }
 
+// Checkout fetches the requested branch from origin and checks out its
+// tip so new commits are based on the branch's history. Branch is set
+// even if the branch does not exist yet, in which case
+// ErrBranchDoesNotExist is returned and the repository is left as is so
+// the first commit of the branch can be pushed for review.
+func (r *Repository) Checkout(branch string) error {
+	logger := r.log.WithFields(log.Fields{
+		"phase":  "checkout",
+		"branch": branch,
+	})
+	r.Branch = branch
+	_, stderr, err := r.Git(append(DefaultGitCommands["fetch"], "refs/heads/"+branch))
+	if err != nil {
+		if strings.Contains(stderr, "couldn't find remote ref") {
+			return ErrBranchDoesNotExist
+		}
+		logger.WithField("stderr", stderr).WithError(err).Error()
+		return err
+	}
+	_, stderr, err = r.Git(append(DefaultGitCommands["checkout"], "FETCH_HEAD"))
+	if err != nil {
+		logger.WithField("stderr", stderr).WithError(err).Error()
+	}
+	return err
+}
+
 // GetRemote will return the url for the given remote name. If the requested
 // remote does not exist ErrRemoteDoesNotExist will be returned.
 func (r *Repository) GetRemote(name string) (string, error) {
@@ -290,7 +319,7 @@ func (r *Repository) Destroy() error {
 // NewRepository constructs and returns a *Repository struct. It will also
 // ensure the repository is properly setup before returning.
 func NewRepository(config *Config) (*Repository, error) {
-	root, err := ioutil.TempDir("", fmt.Sprintf("%s-", ProjectName))
+	root, err := ioutil.TempDir("", tempPrefix(""))
 	if err != nil {
 		return nil, err
 	}
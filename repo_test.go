@@ -120,3 +120,51 @@ func (s *RepoTest) TestDestroy(c *C) {
 	_, err := os.Stat(repo.Root)
 	c.Assert(os.IsNotExist(err), Equals, true)
 }
+
+// newOrigin returns a bare repository to use as origin which contains
+// a single commit on master.
+func (s *RepoTest) newOrigin(c *C) string {
+	origin := c.MkDir()
+	bare := &Repository{log: s.newRepository(c).log, Root: origin}
+	_, _, err := bare.Git([]string{"init", "--quiet", "--bare"})
+	c.Assert(err, IsNil)
+
+	repo := s.newRepository(c)
+	c.Assert(repo.AddRemote("origin", origin), IsNil)
+	c.Assert(repo.Commit("initial"), IsNil)
+	c.Assert(repo.Push("HEAD:refs/heads/master"), IsNil)
+	return origin
+}
+
+func (s *RepoTest) TestCheckout(c *C) {
+	origin := s.newOrigin(c)
+	repo := s.newRepository(c)
+	c.Assert(repo.AddRemote("origin", origin), IsNil)
+	c.Assert(repo.Checkout("master"), IsNil)
+	c.Assert(repo.Branch, Equals, "master")
+	count, _, err := repo.Git([]string{"rev-list", "--count", "HEAD"})
+	c.Assert(err, IsNil)
+	c.Assert(strings.TrimSpace(count), Equals, "1")
+}
+
+func (s *RepoTest) TestCheckout_ErrBranchDoesNotExist(c *C) {
+	origin := s.newOrigin(c)
+	repo := s.newRepository(c)
+	c.Assert(repo.AddRemote("origin", origin), IsNil)
+	c.Assert(repo.Checkout("release"), Equals, ErrBranchDoesNotExist)
+	c.Assert(repo.Branch, Equals, "release")
+}
+
+func (s *RepoTest) TestPush_Branch(c *C) {
+	origin := s.newOrigin(c)
+	repo := s.newRepository(c)
+	c.Assert(repo.AddRemote("origin", origin), IsNil)
+	c.Assert(repo.Checkout("master"), IsNil)
+	repo.Branch = "release"
+	c.Assert(repo.Commit("fix"), IsNil)
+	c.Assert(repo.Push(""), IsNil)
+
+	bare := &Repository{log: repo.log, Root: origin}
+	_, _, err := bare.Git([]string{"rev-parse", "--verify", "refs/for/release"})
+	c.Assert(err, IsNil)
+}
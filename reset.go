@@ -0,0 +1,258 @@
+package gerrittest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-gerrit"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ResetKeepProjects contains the projects which Reset() will not
+	// delete.
+	ResetKeepProjects = []string{"All-Projects", "All-Users"}
+
+	// ResetKeepGroups contains the internal groups which Reset() will
+	// not delete. System groups, such as 'Registered Users', are always
+	// kept.
+	ResetKeepGroups = []string{"Administrators", "Non-Interactive Users"}
+
+	// ResetAccountsPageSize is the number of accounts Reset() requests
+	// from Gerrit at a time.
+	ResetAccountsPageSize = 100
+
+	// ErrConfigRevisionUnknown is returned by Reset() if the revision of
+	// refs/meta/config produced by the setup steps is not known.
+	ErrConfigRevisionUnknown = errors.New("config revision unknown")
+)
+
+// contains returns true if value is present in values.
+func contains(values []string, value string) bool {
+	for _, entry := range values {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
+
+// resetChanges abandons all open changes.
+func (g *Gerrit) resetChanges(client *gerrit.Client) error {
+	logger := g.log.WithFields(log.Fields{
+		"phase": "reset",
+		"task":  "changes",
+	})
+	for {
+		changes, _, err := client.Changes.QueryChanges(&gerrit.QueryChangeOptions{
+			QueryOptions: gerrit.QueryOptions{Query: []string{"status:open"}},
+		})
+		if err != nil {
+			return g.errLog(logger, err)
+		}
+		if len(*changes) == 0 {
+			return nil
+		}
+		for _, change := range *changes {
+			logger.WithField("id", change.ID).Debug()
+			if _, _, err := client.Changes.AbandonChange(change.ID, &gerrit.AbandonInput{
+				Notify: "NONE",
+			}); err != nil {
+				return g.errLog(logger, err)
+			}
+		}
+	}
+}
+
+// resetProjects deletes every project not listed in ResetKeepProjects.
+// Gerrit does not provide an API to delete projects so the repositories
+// are removed from the site directly.
+func (g *Gerrit) resetProjects(client *gerrit.Client) error {
+	logger := g.log.WithFields(log.Fields{
+		"phase": "reset",
+		"task":  "projects",
+	})
+	projects, _, err := client.Projects.ListProjects(&gerrit.ProjectOptions{})
+	if err != nil {
+		return g.errLog(logger, err)
+	}
+	for name := range *projects {
+		if contains(ResetKeepProjects, name) {
+			continue
+		}
+		logger.WithField("project", name).Debug()
+		if _, _, err := g.Container.Exec(
+			"sh", "-c", `rm -rf "${GERRIT_SITE}/git/${1}.git"`, "sh", name); err != nil {
+			return g.errLog(logger, err)
+		}
+	}
+	return nil
+}
+
+// accountPageEntry is an entry in the response to an account query. The
+// last entry sets MoreAccounts if there are more results.
+type accountPageEntry struct {
+	AccountID    int  `json:"_account_id"`
+	MoreAccounts bool `json:"_more_accounts"`
+}
+
+// activeAccounts returns the ids of every active account. The query is
+// paged because Gerrit limits the number of results per request.
+func (g *Gerrit) activeAccounts() ([]int, error) {
+	ids := []int{}
+	for {
+		path := fmt.Sprintf(
+			"/accounts/?q=is:active&n=%d&S=%d", ResetAccountsPageSize, len(ids))
+		response, body, err := g.HTTP.API(http.MethodGet, path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: %s", path, response.Status)
+		}
+		page := []accountPageEntry{}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		for _, entry := range page {
+			ids = append(ids, entry.AccountID)
+		}
+		if len(page) == 0 || !page[len(page)-1].MoreAccounts {
+			return ids, nil
+		}
+	}
+}
+
+// resetAccounts deactivates every active account other than the admin
+// account. Gerrit does not provide a way to delete accounts so
+// CreateUser() and HTTPClient.As() reactivate accounts which exist.
+func (g *Gerrit) resetAccounts(client *gerrit.Client) error {
+	logger := g.log.WithFields(log.Fields{
+		"phase": "reset",
+		"task":  "accounts",
+	})
+	self, _, err := client.Accounts.GetAccount("self")
+	if err != nil {
+		return g.errLog(logger, err)
+	}
+
+	// All the accounts are listed before any are deactivated so the
+	// pages don't shift while they're being read.
+	ids, err := g.activeAccounts()
+	if err != nil {
+		return g.errLog(logger, err)
+	}
+	for _, id := range ids {
+		if id == self.AccountID {
+			continue
+		}
+		logger.WithField("account", id).Debug()
+		if _, err := client.Accounts.DeleteActive(strconv.Itoa(id)); err != nil {
+			return g.errLog(logger, err)
+		}
+	}
+	return nil
+}
+
+// resetGroups deletes every internal group not listed in ResetKeepGroups.
+// Gerrit does not provide an API to delete groups so the groups are
+// removed from the database directly.
+func (g *Gerrit) resetGroups(client *gerrit.Client) error {
+	logger := g.log.WithFields(log.Fields{
+		"phase": "reset",
+		"task":  "groups",
+	})
+	groups, _, err := client.Groups.ListGroups(&gerrit.ListGroupsOptions{})
+	if err != nil {
+		return g.errLog(logger, err)
+	}
+	for name, group := range *groups {
+		if contains(ResetKeepGroups, name) || strings.HasPrefix(group.ID, "global") {
+			continue
+		}
+		logger.WithField("group", name).Debug()
+		for _, statement := range []string{
+			"DELETE FROM account_group_members WHERE group_id = %[1]d",
+			"DELETE FROM account_group_members_audit WHERE group_id = %[1]d",
+			"DELETE FROM account_group_by_id WHERE group_id = %[1]d OR include_uuid = '%[2]s'",
+			"DELETE FROM account_group_by_id_aud WHERE group_id = %[1]d OR include_uuid = '%[2]s'",
+			"DELETE FROM account_group_names WHERE group_id = %[1]d",
+			"DELETE FROM account_groups WHERE group_id = %[1]d",
+		} {
+			sql := fmt.Sprintf(statement, group.GroupID, group.ID)
+			if _, _, err := g.SSH.Run(fmt.Sprintf(`gerrit gsql -c "%s"`, sql)); err != nil {
+				return g.errLog(logger, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resetConfig restores refs/meta/config in All-Projects to ConfigRevision.
+func (g *Gerrit) resetConfig() error {
+	logger := g.log.WithFields(log.Fields{
+		"phase":    "reset",
+		"task":     "config",
+		"revision": g.ConfigRevision,
+	})
+	logger.Debug()
+	if g.ConfigRevision == "" {
+		return g.errLog(logger, ErrConfigRevisionUnknown)
+	}
+
+	repo, err := g.checkoutConfig("All-Projects")
+	if err != nil {
+		return g.errLog(logger, err)
+	}
+	defer repo.Destroy() // nolint: errcheck
+
+	if _, _, err := repo.Git([]string{"read-tree", "-u", "--reset", g.ConfigRevision}); err != nil {
+		return g.errLog(logger, err)
+	}
+	return g.pushConfigChanges(repo, "reset project config")
+}
+
+// Reset returns a running instance to the state it was in once the setup
+// steps completed without restarting the container. Open changes are
+// abandoned, projects other than ResetKeepProjects are deleted, accounts
+// other than the admin account are deactivated, internal groups other
+// than ResetKeepGroups are deleted and refs/meta/config in All-Projects
+// is restored to ConfigRevision. Gerrit can't delete accounts so their
+// usernames remain taken, CreateUser() and HTTPClient.As() reactivate
+// such accounts so the same usernames may be used after Reset().
+func (g *Gerrit) Reset() error {
+	logger := g.log.WithField("phase", "reset")
+	logger.WithField("task", "begin").Debug()
+	client, err := g.HTTP.Gerrit()
+	if err != nil {
+		return g.errLog(logger, err)
+	}
+
+	if err := g.resetChanges(client); err != nil {
+		return err
+	}
+	if err := g.resetProjects(client); err != nil {
+		return err
+	}
+	if err := g.resetAccounts(client); err != nil {
+		return err
+	}
+	if err := g.resetGroups(client); err != nil {
+		return err
+	}
+	if err := g.resetConfig(); err != nil {
+		return err
+	}
+
+	// Projects and groups were modified outside of Gerrit's APIs so
+	// the caches must be flushed.
+	if _, _, err := g.SSH.Run("gerrit flush-caches --all"); err != nil {
+		return g.errLog(logger, err)
+	}
+	logger.WithField("task", "end").Debug()
+	return nil
+}
@@ -0,0 +1,144 @@
+package gerrittest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type ResetTest struct {
+	gerrit *Gerrit
+}
+
+var _ = Suite(&ResetTest{})
+
+func (s *ResetTest) SetUpSuite(c *C) {
+	if testing.Short() {
+		return
+	}
+
+	gerrit, err := New(NewConfig())
+	if err != nil {
+		c.Fatal(err)
+	}
+	s.gerrit = gerrit
+}
+
+func (s *ResetTest) TearDownSuite(c *C) {
+	if s.gerrit != nil {
+		c.Assert(s.gerrit.Destroy(), IsNil)
+	}
+}
+
+func (s *ResetTest) TestContains(c *C) {
+	c.Assert(contains([]string{"a", "b"}, "b"), Equals, true)
+	c.Assert(contains([]string{"a", "b"}, "c"), Equals, false)
+	c.Assert(contains(nil, "c"), Equals, false)
+}
+
+func (s *ResetTest) TestGerrit_activeAccounts(c *C) {
+	defer func(size int) { ResetAccountsPageSize = size }(ResetAccountsPageSize)
+	ResetAccountsPageSize = 2
+	pages := map[string]string{
+		"0": `[{"_account_id": 1}, {"_account_id": 2, "_more_accounts": true}]`,
+		"2": `[{"_account_id": 3}]`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/a/accounts/")
+		c.Check(r.URL.Query().Get("q"), Equals, "is:active")
+		c.Check(r.URL.Query().Get("n"), Equals, "2")
+		fmt.Fprint(w, ")]}'\n"+pages[r.URL.Query().Get("S")]) // nolint: errcheck
+	}))
+	defer ts.Close()
+
+	config := NewConfig()
+	config.Username = "admin"
+	config.Password = "secret"
+	g := &Gerrit{Config: config}
+	g.HTTP = &HTTPClient{
+		config: config,
+		client: &http.Client{Jar: NewCookieJar()},
+		Prefix: ts.URL,
+	}
+	ids, err := g.activeAccounts()
+	c.Assert(err, IsNil)
+	c.Assert(ids, DeepEquals, []int{1, 2, 3})
+}
+
+func (s *ResetTest) TestGerrit_activeAccounts_error(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	config := NewConfig()
+	config.Username = "admin"
+	config.Password = "secret"
+	g := &Gerrit{Config: config}
+	g.HTTP = &HTTPClient{
+		config: config,
+		client: &http.Client{Jar: NewCookieJar()},
+		Prefix: ts.URL,
+	}
+	_, err := g.activeAccounts()
+	c.Assert(err, ErrorMatches, "GET /accounts/.*: 403 Forbidden")
+}
+
+func (s *ResetTest) TestReset_reuseUsername(c *C) {
+	if testing.Short() {
+		c.Skip("-short provided")
+	}
+	username := generaRandomString(12)
+	before, err := s.gerrit.CreateUser(username, username+"@localhost", nil)
+	c.Assert(err, IsNil)
+	c.Assert(before.Destroy(), IsNil)
+
+	c.Assert(s.gerrit.Reset(), IsNil)
+
+	after, err := s.gerrit.CreateUser(username, username+"@localhost", nil)
+	c.Assert(err, IsNil)
+	defer after.Destroy() // nolint: errcheck
+	client, err := after.HTTP.Gerrit()
+	c.Assert(err, IsNil)
+	info, _, err := client.Accounts.GetAccount("self")
+	c.Assert(err, IsNil)
+	c.Assert(info.Username, Equals, username)
+	_, _, err = after.SSH.Run("gerrit version")
+	c.Assert(err, IsNil)
+
+	c.Assert(s.gerrit.Reset(), IsNil)
+	as, err := s.gerrit.HTTP.As(username)
+	c.Assert(err, IsNil)
+	response, _, err := as.API(http.MethodGet, "/accounts/self", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+}
+
+func (s *ResetTest) TestReset(c *C) {
+	if testing.Short() {
+		c.Skip("-short provided")
+	}
+	project := generaRandomString(16)
+	created, err := s.gerrit.CreateProject(project, nil)
+	c.Assert(err, IsNil)
+	change, err := created.CreateChange("", generaRandomString(6))
+	c.Assert(err, IsNil)
+	defer change.Destroy() // nolint: errcheck
+	c.Assert(change.Add("README", 0600, generaRandomString(64)), IsNil)
+	c.Assert(change.Push(), IsNil)
+
+	c.Assert(s.gerrit.Reset(), IsNil)
+
+	client, err := s.gerrit.HTTP.Gerrit()
+	c.Assert(err, IsNil)
+	_, response, err := client.Projects.GetProject(project)
+	c.Assert(err, NotNil)
+	c.Assert(response.StatusCode, Equals, http.StatusNotFound)
+
+	info, _, err := client.Changes.GetChange(change.ChangeID, nil)
+	c.Assert(err, IsNil)
+	c.Assert(info.Status, Equals, "ABANDONED")
+}
@@ -0,0 +1,104 @@
+package gerrittest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/opalmer/dockertest"
+)
+
+var (
+	// ErrContainerNotFound is returned by a Runtime when the requested
+	// container does not exist.
+	ErrContainerNotFound = errors.New("container not found")
+
+	// ErrImageNotFound is returned by a Runtime when the requested
+	// image does not exist.
+	ErrImageNotFound = errors.New("image not found")
+)
+
+// ContainerState describes a container managed by a Runtime.
+type ContainerState struct {
+	// ID is the id of the container.
+	ID string
+
+	// Image is the image the container was created from.
+	Image string
+
+	// Status is a short description of the container's state
+	// such as "running" or "exited".
+	Status string
+
+	// Running is true if the container is currently running.
+	Running bool
+
+	// Labels contains the labels applied to the container.
+	Labels map[string]string
+
+	// Created is the time the container was created.
+	Created time.Time
+
+	// HTTP and SSH are the published ports for Gerrit's http and ssh
+	// services. These will be nil if the port is not published, for
+	// example because the container is no longer running.
+	HTTP *dockertest.Port
+	SSH  *dockertest.Port
+}
+
+// Runtime is used by Container to create and manage the container Gerrit
+// runs inside of. NewDockerRuntime() returns the default implementation
+// which uses Docker. FakeRuntime may be used to test code that depends on
+// *Gerrit without a Docker daemon.
+type Runtime interface {
+	// Start creates and starts a new container using the provided
+	// input. The id of the new container is returned.
+	Start(ctx context.Context, input *ContainerInput) (string, error)
+
+	// Inspect returns the current state of the requested container.
+	Inspect(ctx context.Context, id string) (*ContainerState, error)
+
+	// List returns all containers matching the image, labels and status
+	// of the provided input. An empty image or status matches any value.
+	List(ctx context.Context, input *dockertest.ClientInput) ([]*ContainerState, error)
+
+	// Logs writes the console log of the container to writer. tail
+	// is the number of lines from the end of the log to write or "all".
+	// If follow is true Logs will continue writing to writer until ctx
+	// is cancelled or the container exits.
+	Logs(ctx context.Context, id string, writer io.Writer, follow bool, tail string) error
+
+	// Exec runs a command inside of the container and returns its
+	// stdout and stderr. An error is returned if the command exits
+	// non-zero.
+	Exec(ctx context.Context, id string, command []string) ([]byte, []byte, error)
+
+	// Remove forcefully removes the container. Removing a container which
+	// does not exist is not an error.
+	Remove(ctx context.Context, id string) error
+
+	// Commit creates a new image from the container, tagged with the
+	// provided reference and labels. The id of the new image is returned.
+	Commit(ctx context.Context, id string, reference string, labels map[string]string) (string, error)
+
+	// ImageLabels returns the labels applied to the requested image.
+	ImageLabels(ctx context.Context, image string) (map[string]string, error)
+}
+
+// matches returns true if the provided state matches the image, labels
+// and status of input.
+func matches(state *ContainerState, input *dockertest.ClientInput) bool {
+	if input.Image != "" && state.Image != input.Image {
+		return false
+	}
+	if input.Status != "" && state.Status != input.Status {
+		return false
+	}
+	for key, value := range input.Labels {
+		if current, set := state.Labels[key]; !set || current != value {
+			return false
+		}
+	}
+	return true
+}
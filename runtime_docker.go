@@ -0,0 +1,243 @@
+package gerrittest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/opalmer/dockertest"
+	log "github.com/sirupsen/logrus"
+)
+
+// DockerRuntime is the default Runtime. It uses dockertest along with
+// the Docker API to manage containers.
+type DockerRuntime struct {
+	client *dockertest.DockerClient
+	docker *client.Client
+}
+
+// newContainerState converts *dockertest.ContainerInfo to *ContainerState.
+func newContainerState(info *dockertest.ContainerInfo) *ContainerState {
+	state := &ContainerState{
+		ID:      info.ID(),
+		Image:   info.Data.Image,
+		Status:  info.Data.State,
+		Labels:  info.Data.Labels,
+		Created: time.Unix(info.Data.Created, 0),
+	}
+	if info.State != nil {
+		state.Status = info.State.Status
+		state.Running = info.State.Running
+	}
+	if port, err := info.Port(ExportedHTTPPort); err == nil {
+		state.HTTP = port
+	}
+	if port, err := info.Port(ExportedSSHPort); err == nil {
+		state.SSH = port
+	}
+	return state
+}
+
+// Start creates and starts a new container. The image will be pulled
+// if it does not exist locally. Creating the container is only retried
+// once after pulling so a missing image or network can't cause Start to
+// retry forever.
+func (d *DockerRuntime) Start(ctx context.Context, input *ContainerInput) (string, error) {
+	bindings, err := input.Ports.Bindings()
+	if err != nil {
+		return "", err
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings: bindings,
+		Binds:        input.Binds,
+		NetworkMode:  container.NetworkMode(input.Network),
+		Resources: container.Resources{
+			Memory:   input.Memory,
+			NanoCPUs: input.NanoCPUs,
+		},
+	}
+
+	pulled := false
+	for {
+		created, err := d.docker.ContainerCreate(
+			ctx, input.ContainerConfig(), hostConfig,
+			&network.NetworkingConfig{}, input.Name)
+		if client.IsErrNotFound(err) && !pulled {
+			if err := d.pull(ctx, input.Image); err != nil {
+				return "", err
+			}
+			pulled = true
+			continue
+		}
+		if err != nil && input.Network != "" {
+			return "", fmt.Errorf(
+				"failed to create container on network %q: %s", input.Network, err)
+		}
+		if err != nil {
+			return "", err
+		}
+		err = d.docker.ContainerStart(ctx, created.ID, types.ContainerStartOptions{})
+		if err != nil {
+			d.Remove(context.Background(), created.ID) // nolint: errcheck
+			return "", err
+		}
+		return created.ID, nil
+	}
+}
+
+// pull pulls the requested image.
+func (d *DockerRuntime) pull(ctx context.Context, image string) error {
+	log.WithFields(log.Fields{
+		"cmp":   "container",
+		"phase": "pull",
+		"image": image,
+	}).Debug()
+	reader, err := d.docker.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+	defer reader.Close() // nolint: errcheck
+	_, err = io.Copy(ioutil.Discard, reader)
+	return err
+}
+
+// Inspect returns the current state of the requested container.
+func (d *DockerRuntime) Inspect(ctx context.Context, id string) (*ContainerState, error) {
+	info, err := d.client.ContainerInfo(ctx, id)
+	if err == dockertest.ErrContainerNotFound {
+		return nil, ErrContainerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newContainerState(info), nil
+}
+
+// List returns all containers matching the provided input.
+func (d *DockerRuntime) List(ctx context.Context, input *dockertest.ClientInput) ([]*ContainerState, error) {
+	infos, err := d.client.ListContainers(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	states := []*ContainerState{}
+	for _, info := range infos {
+		states = append(states, newContainerState(info))
+	}
+	return states, nil
+}
+
+// Logs writes the logs for the requested container to writer. Both
+// stdout and stderr are written.
+func (d *DockerRuntime) Logs(ctx context.Context, id string, writer io.Writer, follow bool, tail string) error {
+	reader, err := d.docker.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		Tail:       tail,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close() // nolint: errcheck
+	return demux(reader, writer, writer)
+}
+
+// Exec runs a command inside of the container using 'docker exec'.
+func (d *DockerRuntime) Exec(ctx context.Context, id string, command []string) ([]byte, []byte, error) {
+	logger := log.WithFields(log.Fields{
+		"cmp":   "container",
+		"phase": "exec",
+		"cmd":   strings.Join(command, " "),
+	})
+	config := types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          command,
+	}
+	created, err := d.docker.ContainerExecCreate(ctx, id, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	attached, err := d.docker.ContainerExecAttach(ctx, created.ID, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer attached.Close()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if err := demux(attached.Reader, stdout, stderr); err != nil {
+		return stdout.Bytes(), stderr.Bytes(), err
+	}
+
+	inspection, err := d.docker.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return stdout.Bytes(), stderr.Bytes(), err
+	}
+	logger = logger.WithFields(log.Fields{
+		"stdout": stdout.String(),
+		"stderr": stderr.String(),
+		"code":   inspection.ExitCode,
+	})
+	if inspection.ExitCode != 0 {
+		err = fmt.Errorf("exit status %d", inspection.ExitCode)
+		logger.WithError(err).Error()
+		return stdout.Bytes(), stderr.Bytes(), err
+	}
+	logger.Debug()
+	return stdout.Bytes(), stderr.Bytes(), nil
+}
+
+// Remove forcefully removes the requested container.
+func (d *DockerRuntime) Remove(ctx context.Context, id string) error {
+	return d.client.RemoveContainer(ctx, id)
+}
+
+// Commit creates a new image from the container. The container is paused
+// while the image is being created.
+func (d *DockerRuntime) Commit(ctx context.Context, id string, reference string, labels map[string]string) (string, error) {
+	response, err := d.docker.ContainerCommit(ctx, id, types.ContainerCommitOptions{
+		Reference: reference,
+		Pause:     true,
+		Config:    &container.Config{Labels: labels},
+	})
+	return response.ID, err
+}
+
+// ImageLabels returns the labels applied to the requested image.
+func (d *DockerRuntime) ImageLabels(ctx context.Context, image string) (map[string]string, error) {
+	info, _, err := d.docker.ImageInspectWithRaw(ctx, image)
+	if client.IsErrNotFound(err) {
+		return nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Config == nil || info.Config.Labels == nil {
+		return map[string]string{}, nil
+	}
+	return info.Config.Labels, nil
+}
+
+// NewDockerRuntime returns a Runtime which uses Docker. The connection
+// to Docker is configured using the standard $DOCKER_* environment
+// variables.
+func NewDockerRuntime() (*DockerRuntime, error) {
+	dockertestClient, err := dockertest.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	docker, err := client.NewEnvClient()
+	if err != nil {
+		return nil, err
+	}
+	return &DockerRuntime{client: dockertestClient, docker: docker}, nil
+}
@@ -0,0 +1,87 @@
+package gerrittest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/client"
+	. "gopkg.in/check.v1"
+)
+
+type DockerRuntimeTest struct{}
+
+var _ = Suite(&DockerRuntimeTest{})
+
+// fakeDocker is a Docker API which fails to create containers by
+// responding to every create request with createStatus and createBody.
+type fakeDocker struct {
+	mtx          *sync.Mutex
+	createStatus int
+	createBody   string
+	requests     []string
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	path := r.URL.Path[strings.Index(r.URL.Path[1:], "/")+1:]
+	f.requests = append(f.requests, r.Method+" "+path)
+	w.Header().Set("Content-Type", "application/json")
+	switch path {
+	case "/containers/create":
+		w.WriteHeader(f.createStatus)
+		fmt.Fprint(w, f.createBody) // nolint: errcheck
+	case "/images/create":
+		fmt.Fprint(w, `{"status": "pulled"}`) // nolint: errcheck
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *DockerRuntimeTest) runtime(c *C, docker *fakeDocker) (*DockerRuntime, func()) {
+	server := httptest.NewServer(docker)
+	api, err := client.NewClient(
+		"tcp://"+server.Listener.Addr().String(), "1.25",
+		&http.Client{Transport: &http.Transport{}}, nil)
+	c.Assert(err, IsNil)
+	return &DockerRuntime{docker: api}, server.Close
+}
+
+func (s *DockerRuntimeTest) input(c *C) *ContainerInput {
+	input, err := getDockerClientInput(NewConfig())
+	c.Assert(err, IsNil)
+	return input
+}
+
+func (s *DockerRuntimeTest) TestStart_missingImage(c *C) {
+	docker := &fakeDocker{
+		mtx:          &sync.Mutex{},
+		createStatus: http.StatusNotFound,
+		createBody:   `{"message": "No such image: gerrittest:missing"}`,
+	}
+	runtime, closer := s.runtime(c, docker)
+	defer closer()
+	_, err := runtime.Start(context.Background(), s.input(c))
+	c.Assert(client.IsErrNotFound(err), Equals, true)
+	c.Assert(docker.requests, DeepEquals, []string{
+		"POST /containers/create", "POST /images/create", "POST /containers/create"})
+}
+
+func (s *DockerRuntimeTest) TestStart_missingNetwork(c *C) {
+	docker := &fakeDocker{
+		mtx:          &sync.Mutex{},
+		createStatus: http.StatusNotFound,
+		createBody:   `{"message": "network ci not found"}`,
+	}
+	runtime, closer := s.runtime(c, docker)
+	defer closer()
+	input := s.input(c)
+	input.Network = "ci"
+	_, err := runtime.Start(context.Background(), input)
+	c.Assert(err, ErrorMatches, `failed to create container on network "ci": .*network ci not found`)
+	c.Assert(docker.requests, DeepEquals, []string{"POST /containers/create"})
+}
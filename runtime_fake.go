@@ -0,0 +1,224 @@
+package gerrittest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opalmer/dockertest"
+)
+
+// FakeRuntime is an in-memory Runtime intended for testing. Containers
+// started by FakeRuntime do not run anything; instead every container
+// reports the ports stored in HTTP and SSH which the caller may point at
+// their own services, such as a server from net/http/httptest.
+type FakeRuntime struct {
+	mtx        *sync.Mutex
+	count      int
+	containers map[string]*ContainerState
+	inputs     map[string]*ContainerInput
+	images     map[string]map[string]string
+
+	// HTTP and SSH are the ports reported for every container
+	// started by Start().
+	HTTP *dockertest.Port
+	SSH  *dockertest.Port
+
+	// Log is written by Logs() for every container.
+	Log string
+
+	// ExecFunc, if set, is called by Exec(). By default Exec()
+	// returns no output and no error.
+	ExecFunc func(id string, command []string) ([]byte, []byte, error)
+
+	// Commands contains every command passed to Exec().
+	Commands [][]string
+}
+
+// copyPort returns a copy of port so callers modifying the
+// returned port do not modify the fake's state.
+func copyPort(port *dockertest.Port) *dockertest.Port {
+	if port == nil {
+		return nil
+	}
+	copied := *port
+	return &copied
+}
+
+// copyState returns a copy of state.
+func copyState(state *ContainerState) *ContainerState {
+	copied := *state
+	copied.Labels = map[string]string{}
+	for key, value := range state.Labels {
+		copied.Labels[key] = value
+	}
+	copied.HTTP = copyPort(state.HTTP)
+	copied.SSH = copyPort(state.SSH)
+	return &copied
+}
+
+// Start records a new running container. Like Docker, the container
+// inherits the labels of its image if the image was added by Commit()
+// or AddImage().
+func (f *FakeRuntime) Start(ctx context.Context, input *ContainerInput) (string, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.count++
+	id := fmt.Sprintf("fake%d", f.count)
+	labels := map[string]string{}
+	for key, value := range f.images[input.Image] {
+		labels[key] = value
+	}
+	for key, value := range input.Labels {
+		labels[key] = value
+	}
+	f.containers[id] = &ContainerState{
+		ID:      id,
+		Image:   input.Image,
+		Status:  "running",
+		Running: true,
+		Labels:  labels,
+		Created: time.Now(),
+		HTTP:    copyPort(f.HTTP),
+		SSH:     copyPort(f.SSH),
+	}
+	f.inputs[id] = input
+	return id, nil
+}
+
+// Input returns the input that was passed to Start() for the
+// requested container or nil if the container was never started.
+func (f *FakeRuntime) Input(id string) *ContainerInput {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.inputs[id]
+}
+
+// Inspect returns the state of the requested container.
+func (f *FakeRuntime) Inspect(ctx context.Context, id string) (*ContainerState, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	state, ok := f.containers[id]
+	if !ok {
+		return nil, ErrContainerNotFound
+	}
+	return copyState(state), nil
+}
+
+// List returns the containers matching input.
+func (f *FakeRuntime) List(ctx context.Context, input *dockertest.ClientInput) ([]*ContainerState, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	states := []*ContainerState{}
+	for _, state := range f.containers {
+		if matches(state, input) {
+			states = append(states, copyState(state))
+		}
+	}
+	return states, nil
+}
+
+// Logs writes Log to writer. If tail is not "all" only the
+// requested number of lines from the end of Log are written.
+func (f *FakeRuntime) Logs(ctx context.Context, id string, writer io.Writer, follow bool, tail string) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if _, ok := f.containers[id]; !ok {
+		return ErrContainerNotFound
+	}
+	output := f.Log
+	if tail != "all" {
+		count, err := strconv.Atoi(tail)
+		if err != nil {
+			return err
+		}
+		lines := strings.SplitAfter(strings.TrimRight(output, "\n"), "\n")
+		if len(lines) > count {
+			lines = lines[len(lines)-count:]
+		}
+		output = strings.Join(lines, "")
+	}
+	_, err := io.WriteString(writer, output)
+	return err
+}
+
+// Exec records the command and calls ExecFunc if it's set.
+func (f *FakeRuntime) Exec(ctx context.Context, id string, command []string) ([]byte, []byte, error) {
+	f.mtx.Lock()
+	if _, ok := f.containers[id]; !ok {
+		f.mtx.Unlock()
+		return nil, nil, ErrContainerNotFound
+	}
+	f.Commands = append(f.Commands, command)
+	exec := f.ExecFunc
+	f.mtx.Unlock()
+	if exec == nil {
+		return []byte{}, []byte{}, nil
+	}
+	return exec(id, command)
+}
+
+// Remove deletes the container.
+func (f *FakeRuntime) Remove(ctx context.Context, id string) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	delete(f.containers, id)
+	return nil
+}
+
+// Commit records a new image using the provided labels.
+func (f *FakeRuntime) Commit(ctx context.Context, id string, reference string, labels map[string]string) (string, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if _, ok := f.containers[id]; !ok {
+		return "", ErrContainerNotFound
+	}
+	copied := map[string]string{}
+	for key, value := range labels {
+		copied[key] = value
+	}
+	f.images[reference] = copied
+	return "sha256:" + reference, nil
+}
+
+// AddImage records an image with the provided labels as if it had
+// been pulled.
+func (f *FakeRuntime) AddImage(reference string, labels map[string]string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	copied := map[string]string{}
+	for key, value := range labels {
+		copied[key] = value
+	}
+	f.images[reference] = copied
+}
+
+// ImageLabels returns the labels of an image created by Commit()
+// or AddImage().
+func (f *FakeRuntime) ImageLabels(ctx context.Context, image string) (map[string]string, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	labels, ok := f.images[image]
+	if !ok {
+		return nil, ErrImageNotFound
+	}
+	return labels, nil
+}
+
+// NewFakeRuntime returns a *FakeRuntime whose containers report the
+// provided http and ssh ports.
+func NewFakeRuntime(http *dockertest.Port, ssh *dockertest.Port) *FakeRuntime {
+	return &FakeRuntime{
+		mtx:        &sync.Mutex{},
+		containers: map[string]*ContainerState{},
+		inputs:     map[string]*ContainerInput{},
+		images:     map[string]map[string]string{},
+		HTTP:       http,
+		SSH:        ssh,
+		Commands:   [][]string{},
+	}
+}
@@ -0,0 +1,345 @@
+package gerrittest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/opalmer/dockertest"
+	. "gopkg.in/check.v1"
+)
+
+type RuntimeTest struct {
+	server *httptest.Server
+}
+
+var _ = Suite(&RuntimeTest{})
+
+func (s *RuntimeTest) SetUpTest(c *C) {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func (s *RuntimeTest) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+// runtime returns a *FakeRuntime whose http and ssh ports both point
+// at the test's http server.
+func (s *RuntimeTest) runtime(c *C) *FakeRuntime {
+	split := strings.Split(s.server.Listener.Addr().String(), ":")
+	public, err := strconv.ParseUint(split[1], 10, 16)
+	c.Assert(err, IsNil)
+	return NewFakeRuntime(
+		&dockertest.Port{Address: split[0], Private: ExportedHTTPPort, Public: uint16(public)},
+		&dockertest.Port{Address: split[0], Private: ExportedSSHPort, Public: uint16(public)})
+}
+
+// ready is a probe which always passes.
+func (s *RuntimeTest) ready(context.Context, *dockertest.Port, *dockertest.Port) error {
+	return nil
+}
+
+func (s *RuntimeTest) config(c *C, runtime Runtime) *Config {
+	cfg := NewConfig()
+	cfg.Runtime = runtime
+	cfg.SkipSetup = true
+	cfg.Probes = []ReadinessProbe{
+		NewFuncProbe("ready", time.Second, time.Millisecond, s.ready),
+	}
+	return cfg
+}
+
+func (s *RuntimeTest) Test_matches(c *C) {
+	state := &ContainerState{
+		Image:  "image",
+		Status: "running",
+		Labels: map[string]string{"a": "1"},
+	}
+	input := dockertest.NewClientInput("image")
+	input.RemoveLabel("dockertest")
+	c.Assert(matches(state, input), Equals, true)
+	input.SetLabel("a", "1")
+	input.Status = "running"
+	c.Assert(matches(state, input), Equals, true)
+	input.SetLabel("a", "2")
+	c.Assert(matches(state, input), Equals, false)
+	input.SetLabel("a", "1")
+	input.Status = "exited"
+	c.Assert(matches(state, input), Equals, false)
+	c.Assert(matches(state, dockertest.NewClientInput("other")), Equals, false)
+}
+
+func (s *RuntimeTest) Test_newContainerState(c *C) {
+	state := newContainerState(&dockertest.ContainerInfo{
+		Data: types.Container{
+			ID:      "abc",
+			Image:   "image",
+			State:   "running",
+			Created: 10,
+			Labels:  map[string]string{"a": "1"},
+			Ports: []types.Port{
+				{IP: "127.0.0.1", PrivatePort: ExportedHTTPPort, PublicPort: 1, Type: "tcp"},
+				{IP: "127.0.0.1", PrivatePort: ExportedSSHPort, PublicPort: 2, Type: "tcp"},
+			},
+		},
+		State: &types.ContainerState{Status: "running", Running: true},
+	})
+	c.Assert(state.ID, Equals, "abc")
+	c.Assert(state.Image, Equals, "image")
+	c.Assert(state.Status, Equals, "running")
+	c.Assert(state.Running, Equals, true)
+	c.Assert(state.Created, Equals, time.Unix(10, 0))
+	c.Assert(state.Labels, DeepEquals, map[string]string{"a": "1"})
+	c.Assert(state.HTTP.Public, Equals, uint16(1))
+	c.Assert(state.SSH.Public, Equals, uint16(2))
+}
+
+func (s *RuntimeTest) Test_newContainerState_noPorts(c *C) {
+	state := newContainerState(&dockertest.ContainerInfo{})
+	c.Assert(state.HTTP, IsNil)
+	c.Assert(state.SSH, IsNil)
+}
+
+func (s *RuntimeTest) TestFakeRuntime(c *C) {
+	ctx := context.Background()
+	runtime := s.runtime(c)
+	runtime.Log = "one\ntwo\nthree\n"
+	id, err := runtime.Start(ctx, &ContainerInput{ClientInput: dockertest.NewClientInput("image")})
+	c.Assert(err, IsNil)
+	c.Assert(runtime.Input(id).Image, Equals, "image")
+
+	state, err := runtime.Inspect(ctx, id)
+	c.Assert(err, IsNil)
+	c.Assert(state.Running, Equals, true)
+	c.Assert(state.HTTP, DeepEquals, runtime.HTTP)
+
+	states, err := runtime.List(ctx, dockertest.NewClientInput("image"))
+	c.Assert(err, IsNil)
+	c.Assert(states, HasLen, 1)
+	states, err = runtime.List(ctx, dockertest.NewClientInput("other"))
+	c.Assert(err, IsNil)
+	c.Assert(states, HasLen, 0)
+
+	output := &bytes.Buffer{}
+	c.Assert(runtime.Logs(ctx, id, output, false, "2"), IsNil)
+	c.Assert(output.String(), Equals, "two\nthree")
+
+	_, _, err = runtime.Exec(ctx, id, []string{"true"})
+	c.Assert(err, IsNil)
+	c.Assert(runtime.Commands, DeepEquals, [][]string{{"true"}})
+
+	_, err = runtime.Commit(ctx, id, "snapshot", map[string]string{"a": "1"})
+	c.Assert(err, IsNil)
+	labels, err := runtime.ImageLabels(ctx, "snapshot")
+	c.Assert(err, IsNil)
+	c.Assert(labels, DeepEquals, map[string]string{"a": "1"})
+	_, err = runtime.ImageLabels(ctx, "missing")
+	c.Assert(err, Equals, ErrImageNotFound)
+
+	c.Assert(runtime.Remove(ctx, id), IsNil)
+	_, err = runtime.Inspect(ctx, id)
+	c.Assert(err, Equals, ErrContainerNotFound)
+}
+
+func (s *RuntimeTest) TestNew(c *C) {
+	runtime := s.runtime(c)
+	g, err := New(s.config(c, runtime))
+	c.Assert(err, IsNil)
+	c.Assert(g.HTTPPort.Address, Equals, "localhost")
+	c.Assert(g.SSHPort.Public, Equals, runtime.SSH.Public)
+	input := runtime.Input(g.Container.ID)
+	c.Assert(input.Image, Equals, GetDockerImage(""))
+	c.Assert(input.Labels[LabelPID], Equals, strconv.Itoa(os.Getpid()))
+
+	health := g.checkContainer(context.Background())
+	c.Assert(health.OK, Equals, true)
+	c.Assert(health.Detail, Equals, "running")
+
+	c.Assert(g.Destroy(), IsNil)
+	_, err = runtime.Inspect(context.Background(), g.Container.ID)
+	c.Assert(err, Equals, ErrContainerNotFound)
+}
+
+func (s *RuntimeTest) TestNew_startFailure(c *C) {
+	runtime := s.runtime(c)
+	runtime.Log = "starting\nfailed\n"
+	cfg := s.config(c, runtime)
+	cfg.Probes = []ReadinessProbe{
+		NewFuncProbe("failure", time.Millisecond*50, time.Millisecond,
+			func(context.Context, *dockertest.Port, *dockertest.Port) error {
+				return errors.New("not ready")
+			}),
+	}
+	_, err := New(cfg)
+	containerErr, ok := err.(*ContainerError)
+	c.Assert(ok, Equals, true)
+	c.Assert(containerErr.Err, ErrorMatches, `probe "failure" failed: .*`)
+	c.Assert(containerErr.Logs, DeepEquals, []string{"starting", "failed"})
+	_, err = runtime.Inspect(context.Background(), containerErr.ID)
+	c.Assert(err, Equals, ErrContainerNotFound)
+}
+
+func (s *RuntimeTest) TestAcquire(c *C) {
+	runtime := s.runtime(c)
+	first, err := Acquire(s.config(c, runtime))
+	c.Assert(err, IsNil)
+	second, err := Acquire(s.config(c, runtime))
+	c.Assert(err, IsNil)
+	c.Assert(second.Container.ID, Equals, first.Container.ID)
+	_, set := runtime.Input(first.Container.ID).Labels[LabelPID]
+	c.Assert(set, Equals, false)
+	c.Assert(first.Destroy(), IsNil)
+	c.Assert(second.Destroy(), IsNil)
+	_, err = runtime.Inspect(context.Background(), first.Container.ID)
+	c.Assert(err, IsNil)
+}
+
+func (s *RuntimeTest) TestSnapshot(c *C) {
+	runtime := s.runtime(c)
+	cfg := s.config(c, runtime)
+	cfg.Username = "snapshot-user"
+	cfg.Password = "snapshot-password"
+	g, err := New(cfg)
+	c.Assert(err, IsNil)
+	_, err = g.Snapshot("snapshot")
+	c.Assert(err, IsNil)
+
+	// The image must not contain the private key.
+	key := g.Config.SSHKeys[0]
+	private, err := ioutil.ReadFile(key.Path)
+	c.Assert(err, IsNil)
+	labels, err := runtime.ImageLabels(context.Background(), "snapshot")
+	c.Assert(err, IsNil)
+	for _, value := range labels {
+		c.Assert(strings.Contains(value, "PRIVATE KEY"), Equals, false)
+	}
+
+	// The snapshot must remain usable once the instance which created
+	// it is gone and its key has been removed, for example by GC().
+	c.Assert(g.Destroy(), IsNil)
+	c.Assert(os.Remove(key.Path), IsNil)
+
+	cfg = s.config(c, runtime)
+	cfg.SnapshotImage = "snapshot"
+	restored, err := New(cfg)
+	c.Assert(err, IsNil)
+	defer restored.Destroy()                         // nolint: errcheck
+	defer os.Remove(restored.Config.SSHKeys[0].Path) // nolint: errcheck
+	copied, err := ioutil.ReadFile(restored.Config.SSHKeys[0].Path)
+	c.Assert(err, IsNil)
+	c.Assert(copied, DeepEquals, private)
+	c.Assert(restored.Config.SSHKeys[0].Generated, Equals, false)
+	c.Assert(restored.Config.Username, Equals, "snapshot-user")
+	c.Assert(restored.Config.Password, Equals, "snapshot-password")
+	c.Assert(runtime.Input(restored.Container.ID).Image, Equals, "snapshot")
+	c.Assert(restored.Config.SSHKeys, HasLen, 1)
+	c.Assert(restored.Config.SSHKeys[0].Path, Not(Equals), key.Path)
+	c.Assert(
+		restored.Config.SSHKeys[0].Public.Marshal(), DeepEquals, key.Public.Marshal())
+}
+
+func (s *RuntimeTest) TestNew_newSite(c *C) {
+	runtime := s.runtime(c)
+	cfg := s.config(c, runtime)
+	runtime.AddImage(cfg.Image, map[string]string{LabelImageRevision: "1"})
+	cfg.SiteDir = filepath.Join(c.MkDir(), "site")
+	g, err := New(cfg)
+	c.Assert(err, IsNil)
+	defer g.Destroy() // nolint: errcheck
+	c.Assert(g.restored, Equals, false)
+	c.Assert(g.Config.SSHKeys[0].Path, Equals, filepath.Join(cfg.SiteDir, SiteSSHKey))
+	c.Assert(runtime.Input(g.Container.ID).Binds, HasLen, len(SiteMounts))
+}
+
+func (s *RuntimeTest) TestNew_siteAfterFailure(c *C) {
+	runtime := s.runtime(c)
+	cfg := s.config(c, runtime)
+	runtime.AddImage(cfg.Image, map[string]string{LabelImageRevision: "1"})
+	cfg.SiteDir = filepath.Join(c.MkDir(), "site")
+	cfg.Probes = []ReadinessProbe{
+		NewFuncProbe("failure", time.Millisecond*50, time.Millisecond,
+			func(context.Context, *dockertest.Port, *dockertest.Port) error {
+				return errors.New("not ready")
+			}),
+	}
+	_, err := New(cfg)
+	c.Assert(err, NotNil)
+	_, err = os.Stat(filepath.Join(cfg.SiteDir, SiteStateFile))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	key, err := LoadSSHKey(filepath.Join(cfg.SiteDir, SiteSSHKey))
+	c.Assert(err, IsNil)
+
+	cfg = s.config(c, runtime)
+	cfg.SiteDir = filepath.Dir(key.Path)
+	g, err := New(cfg)
+	c.Assert(err, IsNil)
+	defer g.Destroy() // nolint: errcheck
+	c.Assert(g.restored, Equals, false)
+	c.Assert(g.Config.SSHKeys, HasLen, 1)
+	c.Assert(g.Config.SSHKeys[0].Path, Equals, key.Path)
+	c.Assert(
+		g.Config.SSHKeys[0].Public.Marshal(), DeepEquals, key.Public.Marshal())
+}
+
+func (s *RuntimeTest) TestNew_siteUnsupportedImage(c *C) {
+	runtime := s.runtime(c)
+	cfg := s.config(c, runtime)
+	cfg.SiteDir = filepath.Join(c.MkDir(), "site")
+	_, err := New(cfg)
+	c.Assert(err, ErrorMatches, "image .* does not support Config.SiteDir or Config.Memory, .*")
+	containers, err := runtime.List(context.Background(), &dockertest.ClientInput{})
+	c.Assert(err, IsNil)
+	c.Assert(containers, HasLen, 0)
+}
+
+func (s *RuntimeTest) TestNew_memoryUnsupportedImage(c *C) {
+	runtime := s.runtime(c)
+	cfg := s.config(c, runtime)
+	runtime.AddImage(cfg.Image, map[string]string{LabelImageRevision: "0"})
+	cfg.Memory = 1024 * 1024 * 1024
+	_, err := New(cfg)
+	c.Assert(err, ErrorMatches, "image .* does not support Config.SiteDir or Config.Memory, .*")
+
+	runtime.AddImage(cfg.Image, map[string]string{LabelImageRevision: "1"})
+	g, err := New(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(g.Destroy(), IsNil)
+}
+
+func (s *RuntimeTest) TestNew_existingSite(c *C) {
+	dir := c.MkDir()
+	key, err := CreateSSHKey(filepath.Join(dir, SiteSSHKey))
+	c.Assert(err, IsNil)
+	state := &Gerrit{Config: NewConfig(), ConfigRevision: "abc"}
+	state.Config.Username = "site-user"
+	state.Config.Password = "site-password"
+	state.Config.SSHKeys = []*SSHKey{key}
+	c.Assert(state.WriteJSONFile(filepath.Join(dir, SiteStateFile)), IsNil)
+
+	runtime := s.runtime(c)
+	cfg := s.config(c, runtime)
+	runtime.AddImage(cfg.Image, map[string]string{LabelImageRevision: "1"})
+	cfg.SiteDir = dir
+	g, err := New(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(g.restored, Equals, true)
+	c.Assert(g.ConfigRevision, Equals, "abc")
+	c.Assert(g.Config.Username, Equals, "site-user")
+	c.Assert(g.Config.Password, Equals, "site-password")
+	c.Assert(g.Destroy(), IsNil)
+
+	// Data in the site, including the ssh key, should survive Destroy().
+	_, err = os.Stat(key.Path)
+	c.Assert(err, IsNil)
+}
@@ -0,0 +1,344 @@
+package gerrittest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SeedStatusNew leaves a seeded change open.
+	SeedStatusNew = "new"
+
+	// SeedStatusMerged submits a seeded change. The change's votes must
+	// satisfy the project's submit rules, typically Code-Review +2
+	// and Verified +1.
+	SeedStatusMerged = "merged"
+
+	// SeedStatusAbandoned abandons a seeded change.
+	SeedStatusAbandoned = "abandoned"
+)
+
+// SeedProject describes a project to create.
+type SeedProject struct {
+	// Name is the name of the project.
+	Name string `json:"name"`
+
+	// Parent is the project to inherit permissions from. Defaults
+	// to All-Projects.
+	Parent string `json:"parent"`
+
+	// Files maps paths to their contents. The files are pushed
+	// directly to master.
+	Files map[string]string `json:"files"`
+
+	// Branches are additional branches to create from master
+	// after Files have been pushed.
+	Branches []string `json:"branches"`
+}
+
+// SeedAccount describes an account to create.
+type SeedAccount struct {
+	// Username is the username of the account.
+	Username string `json:"username"`
+
+	// Name is the full name of the account.
+	Name string `json:"name"`
+
+	// Email is the preferred email address of the account.
+	Email string `json:"email"`
+
+	// Password is the account's http password.
+	Password string `json:"password"`
+
+	// SSHKey is a public key to add to the account.
+	SSHKey string `json:"ssh_key"`
+}
+
+// SeedGroup describes a group to create.
+type SeedGroup struct {
+	// Name is the name of the group.
+	Name string `json:"name"`
+
+	// Description is the description of the group.
+	Description string `json:"description"`
+
+	// Members contains the usernames of the group's members.
+	Members []string `json:"members"`
+}
+
+// SeedChange describes a change to create.
+type SeedChange struct {
+	// Project is the project to create the change in. Defaults
+	// to ProjectName. The project will be created if it does not exist.
+	Project string `json:"project"`
+
+	// Branch is the branch the change targets. Defaults to master.
+	Branch string `json:"branch"`
+
+	// Subject is the subject of the change's commit message.
+	Subject string `json:"subject"`
+
+	// PatchSets contains the files for each patch set. Each entry maps
+	// paths to their contents and is applied on top of the previous
+	// patch set before it's pushed.
+	PatchSets []map[string]string `json:"patch_sets"`
+
+	// Votes maps label names to the value to apply once every patch
+	// set has been pushed, for example {"Code-Review": 2}.
+	Votes map[string]int `json:"votes"`
+
+	// Comments contains top level comments to add to the change.
+	Comments []string `json:"comments"`
+
+	// Status is the final status of the change: SeedStatusNew,
+	// SeedStatusMerged or SeedStatusAbandoned. Defaults to SeedStatusNew.
+	Status string `json:"status"`
+}
+
+// SeedSpec describes the projects, accounts, groups and changes Seed()
+// should create. Projects are created first followed by accounts, groups
+// and finally changes.
+type SeedSpec struct {
+	Projects []*SeedProject `json:"projects"`
+	Accounts []*SeedAccount `json:"accounts"`
+	Groups   []*SeedGroup   `json:"groups"`
+	Changes  []*SeedChange  `json:"changes"`
+}
+
+// validate checks the spec for errors and applies defaults.
+func (s *SeedSpec) validate() error {
+	for i, project := range s.Projects {
+		if project.Name == "" {
+			return fmt.Errorf("projects[%d]: name not provided", i)
+		}
+	}
+	for i, account := range s.Accounts {
+		if account.Username == "" {
+			return fmt.Errorf("accounts[%d]: username not provided", i)
+		}
+	}
+	for i, group := range s.Groups {
+		if group.Name == "" {
+			return fmt.Errorf("groups[%d]: name not provided", i)
+		}
+	}
+	for i, change := range s.Changes {
+		if change.Subject == "" {
+			return fmt.Errorf("changes[%d]: subject not provided", i)
+		}
+		if change.Project == "" {
+			change.Project = ProjectName
+		}
+		if change.Branch == "" {
+			change.Branch = "master"
+		}
+		switch change.Status {
+		case "":
+			change.Status = SeedStatusNew
+		case SeedStatusNew, SeedStatusMerged, SeedStatusAbandoned:
+		default:
+			return fmt.Errorf("changes[%d]: unknown status %q", i, change.Status)
+		}
+	}
+	return nil
+}
+
+// ReadSeedSpec reads a *SeedSpec from the yaml or json file at path. Both
+// formats use the field names from the json tags on SeedSpec.
+func ReadSeedSpec(path string) (*SeedSpec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec := &SeedSpec{}
+	if err := yaml.Unmarshal(data, spec); err != nil {
+		return nil, err
+	}
+	return spec, spec.validate()
+}
+
+// sortedPaths returns the keys of files in sorted order so files are
+// always added in the same order.
+func sortedPaths(files map[string]string) []string {
+	paths := []string{}
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// seedProject creates the project, unless it already exists, along
+// with its files and branches.
+func (g *Gerrit) seedProject(project *SeedProject) error {
+	logger := g.log.WithFields(log.Fields{
+		"phase":   "seed",
+		"project": project.Name,
+	})
+
+	created, err := g.GetProject(project.Name)
+	if err == ErrProjectNotFound {
+		logger.WithField("action", "create-project").Debug()
+		created, err = g.CreateProject(project.Name, &ProjectOptions{
+			Parent:            project.Parent,
+			CreateEmptyCommit: true,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(project.Files) > 0 {
+		logger.WithField("action", "push-files").Debug()
+		repo, err := NewRepository(g.Config)
+		if err != nil {
+			return err
+		}
+		defer repo.Destroy() // nolint: errcheck
+		if err := repo.AddOriginFromContainer(g.Container, project.Name); err != nil {
+			return err
+		}
+		if err := repo.Checkout("master"); err != nil && err != ErrBranchDoesNotExist {
+			return err
+		}
+		for _, path := range sortedPaths(project.Files) {
+			if err := repo.Add(path, 0644, []byte(project.Files[path])); err != nil {
+				return err
+			}
+		}
+		if err := repo.Commit("Add initial files"); err != nil {
+			return err
+		}
+		if err := repo.Push("HEAD:refs/heads/master"); err != nil {
+			return err
+		}
+	}
+
+	for _, branch := range project.Branches {
+		if err := created.CreateBranch(branch, "master"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedGroup creates the group and adds its members.
+func (g *Gerrit) seedGroup(group *SeedGroup) error {
+	g.log.WithFields(log.Fields{
+		"phase": "seed",
+		"group": group.Name,
+	}).Debug()
+	_, err := g.CreateGroup(group.Name, &GroupOptions{
+		Description: group.Description,
+		Members:     group.Members,
+	})
+	return err
+}
+
+// seedChange creates the change, pushes each patch set and then applies
+// votes, comments and the final status.
+func (g *Gerrit) seedChange(spec *SeedChange) error { // nolint: gocyclo
+	logger := g.log.WithFields(log.Fields{
+		"phase":   "seed",
+		"project": spec.Project,
+		"subject": spec.Subject,
+	})
+	logger.Debug()
+
+	if err := g.seedProject(&SeedProject{Name: spec.Project}); err != nil {
+		return err
+	}
+	change, err := g.CreateChange(g.newProject(spec.Project), spec.Branch, spec.Subject)
+	if err != nil {
+		return err
+	}
+	defer change.Destroy() // nolint: errcheck
+
+	patchSets := spec.PatchSets
+	if len(patchSets) == 0 {
+		patchSets = []map[string]string{{}}
+	}
+	for _, files := range patchSets {
+		for _, path := range sortedPaths(files) {
+			if err := change.Add(path, 0644, files[path]); err != nil {
+				return err
+			}
+		}
+		if err := change.Push(); err != nil {
+			return err
+		}
+	}
+
+	labels := []string{}
+	for label := range spec.Votes {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		if _, err := change.ApplyLabel("", label, spec.Votes[label]); err != nil {
+			return err
+		}
+	}
+	for _, comment := range spec.Comments {
+		if _, err := change.AddTopLevelComment("", comment); err != nil {
+			return err
+		}
+	}
+
+	switch spec.Status {
+	case SeedStatusMerged:
+		_, err = change.Submit()
+	case SeedStatusAbandoned:
+		_, err = change.Abandon()
+	}
+	return err
+}
+
+// Seed creates the projects, accounts, groups and changes described
+// by spec.
+func Seed(g *Gerrit, spec *SeedSpec) error {
+	if err := spec.validate(); err != nil {
+		return err
+	}
+	client, err := g.HTTP.Gerrit()
+	if err != nil {
+		return err
+	}
+
+	for _, project := range spec.Projects {
+		if err := g.seedProject(project); err != nil {
+			return err
+		}
+	}
+	for _, account := range spec.Accounts {
+		g.log.WithFields(log.Fields{
+			"phase":    "seed",
+			"username": account.Username,
+		}).Debug()
+		if _, _, err := client.Accounts.CreateAccount(account.Username, &gerrit.AccountInput{
+			Username:     account.Username,
+			Name:         account.Name,
+			Email:        account.Email,
+			HTTPPassword: account.Password,
+			SSHKey:       account.SSHKey,
+		}); err != nil {
+			return err
+		}
+	}
+	for _, group := range spec.Groups {
+		if err := g.seedGroup(group); err != nil {
+			return err
+		}
+	}
+	for _, change := range spec.Changes {
+		if err := g.seedChange(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package gerrittest
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygrunwald/go-gerrit"
+	. "gopkg.in/check.v1"
+)
+
+type SeedTest struct{}
+
+var _ = Suite(&SeedTest{})
+
+func (s *SeedTest) write(c *C, data string) string {
+	path := filepath.Join(c.MkDir(), "spec.json")
+	c.Assert(ioutil.WriteFile(path, []byte(data), 0600), IsNil)
+	return path
+}
+
+func (s *SeedTest) TestReadSeedSpec(c *C) {
+	spec, err := ReadSeedSpec(s.write(c, `{
+		"projects": [{"name": "foo", "files": {"README": "hello"}, "branches": ["dev"]}],
+		"accounts": [{"username": "jdoe"}],
+		"groups": [{"name": "devs", "members": ["jdoe"]}],
+		"changes": [{"subject": "Add foo", "votes": {"Code-Review": 2}}]
+	}`))
+	c.Assert(err, IsNil)
+	c.Assert(spec.Projects[0].Files, DeepEquals, map[string]string{"README": "hello"})
+	c.Assert(spec.Groups[0].Members, DeepEquals, []string{"jdoe"})
+	change := spec.Changes[0]
+	c.Assert(change.Project, Equals, ProjectName)
+	c.Assert(change.Branch, Equals, "master")
+	c.Assert(change.Status, Equals, SeedStatusNew)
+	c.Assert(change.Votes, DeepEquals, map[string]int{"Code-Review": 2})
+}
+
+func (s *SeedTest) TestReadSeedSpec_yaml(c *C) {
+	spec, err := ReadSeedSpec(s.write(c, `
+projects:
+  - name: foo
+    files:
+      README: hello
+    branches: [dev]
+accounts:
+  - username: jdoe
+    ssh_key: ssh-rsa AAAA
+changes:
+  - subject: Add foo
+    patch_sets:
+      - foo.txt: v1
+      - foo.txt: v2
+    votes:
+      Code-Review: 2
+    status: merged
+`))
+	c.Assert(err, IsNil)
+	c.Assert(spec.Projects[0].Files, DeepEquals, map[string]string{"README": "hello"})
+	c.Assert(spec.Projects[0].Branches, DeepEquals, []string{"dev"})
+	c.Assert(spec.Accounts[0].SSHKey, Equals, "ssh-rsa AAAA")
+	change := spec.Changes[0]
+	c.Assert(change.PatchSets, DeepEquals, []map[string]string{
+		{"foo.txt": "v1"}, {"foo.txt": "v2"}})
+	c.Assert(change.Votes, DeepEquals, map[string]int{"Code-Review": 2})
+	c.Assert(change.Status, Equals, SeedStatusMerged)
+}
+
+func (s *SeedTest) TestReadSeedSpec_errors(c *C) {
+	for spec, expected := range map[string]string{
+		`{`:                  ".*did not find expected node content",
+		`{"projects": [{}]}`: `projects\[0\]: name not provided`,
+		`{"accounts": [{}]}`: `accounts\[0\]: username not provided`,
+		`{"groups": [{}]}`:   `groups\[0\]: name not provided`,
+		`{"changes": [{"subject": "a", "status": "x"}]}`: `changes\[0\]: unknown status "x"`,
+	} {
+		_, err := ReadSeedSpec(s.write(c, spec))
+		c.Assert(err, ErrorMatches, expected)
+	}
+}
+
+func (s *SeedTest) TestSeed(c *C) {
+	if testing.Short() {
+		c.Skip("-short provided")
+	}
+	g, err := New(NewConfig())
+	c.Assert(err, IsNil)
+	defer g.Destroy() // nolint: errcheck
+
+	project := generaRandomString(16)
+	c.Assert(Seed(g, &SeedSpec{
+		Projects: []*SeedProject{{
+			Name:     project,
+			Files:    map[string]string{"README": "hello"},
+			Branches: []string{"dev"},
+		}},
+		Changes: []*SeedChange{{
+			Project: project,
+			Branch:  "dev",
+			Subject: "Update README",
+			PatchSets: []map[string]string{
+				{"README": "one"},
+				{"README": "two"},
+			},
+			Votes:  map[string]int{CodeReviewLabel: 2, VerifiedLabel: 1},
+			Status: SeedStatusMerged,
+		}},
+	}), IsNil)
+
+	client, err := g.HTTP.Gerrit()
+	c.Assert(err, IsNil)
+	changes, _, err := client.Changes.QueryChanges(&gerrit.QueryChangeOptions{
+		QueryOptions: gerrit.QueryOptions{Query: []string{"project:" + project}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(*changes, HasLen, 1)
+	c.Assert((*changes)[0].Status, Equals, "MERGED")
+	c.Assert((*changes)[0].Branch, Equals, "dev")
+}
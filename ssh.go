@@ -2,14 +2,19 @@ package gerrittest
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/opalmer/dockertest"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // SSHClient implements an SSH client for talking to
@@ -57,18 +62,167 @@ func (s *SSHClient) Run(command string) ([]byte, []byte, error) {
 	} else {
 		logger.Debug()
 	}
-	return stdout.Bytes(), stderr.Bytes(), nil
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// exitStatus converts the error returned by a session into the exit
+// status of the remote command. Non-zero exit statuses are not considered
+// to be errors.
+func exitStatus(err error) (int, error) {
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		return exitErr.ExitStatus(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// Stream executes a command over ssh copying stdin to the command and the
+// command's output to stdout and stderr as it's produced. The exit status
+// of the command is returned.
+func (s *SSHClient) Stream(command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) (int, error) {
+	logger := s.log.WithFields(log.Fields{
+		"phase": "stream",
+		"cmd":   command,
+	})
+	session, err := s.Client.NewSession()
+	if err != nil {
+		logger.WithError(err).Error()
+		return -1, err
+	}
+	defer session.Close() // nolint: errcheck
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+	code, err := exitStatus(session.Run(command))
+	logger.WithField("code", code).Debug()
+	return code, err
+}
+
+// Interactive is similar to Stream except a pseudo terminal is requested
+// for the command. If command is empty an interactive shell is started
+// instead. When stdin is a terminal it's placed into raw mode until the
+// command exits.
+func (s *SSHClient) Interactive(command string, stdin *os.File, stdout io.Writer, stderr io.Writer) (int, error) {
+	logger := s.log.WithFields(log.Fields{
+		"phase": "interactive",
+		"cmd":   command,
+	})
+	session, err := s.Client.NewSession()
+	if err != nil {
+		logger.WithError(err).Error()
+		return -1, err
+	}
+	defer session.Close() // nolint: errcheck
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	width, height := 80, 24
+	fd := int(stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return -1, err
+		}
+		defer terminal.Restore(fd, state) // nolint: errcheck
+		if w, h, err := terminal.GetSize(fd); err == nil {
+			width, height = w, h
+		}
+	}
+	term := os.Getenv("TERM")
+	if term == "" {
+		term = "xterm"
+	}
+	if err := session.RequestPty(term, height, width, ssh.TerminalModes{}); err != nil {
+		logger.WithError(err).Error()
+		return -1, err
+	}
+
+	if command == "" {
+		if err := session.Shell(); err != nil {
+			return -1, err
+		}
+		err = session.Wait()
+	} else {
+		err = session.Run(command)
+	}
+	code, err := exitStatus(err)
+	logger.WithField("code", code).Debug()
+	return code, err
 }
 
 // Version returns the current version of Gerrit.
 func (s *SSHClient) Version() (string, error) {
-	stdout, _, err := s.Run("gerrit version")
-	return strings.Split(strings.TrimSpace(string(stdout)), " ")[2], err
+	return s.version(context.Background())
+}
+
+// version is similar to Version except it returns ctx.Err() if ctx is
+// done before the command completes.
+func (s *SSHClient) version(ctx context.Context) (string, error) {
+	type result struct {
+		stdout []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		stdout, _, err := s.Run("gerrit version")
+		done <- result{stdout: stdout, err: err}
+	}()
+
+	var stdout []byte
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case output := <-done:
+		if output.err != nil {
+			return "", output.err
+		}
+		stdout = output.stdout
+	}
+	fields := strings.Fields(string(stdout))
+	if len(fields) < 3 {
+		return "", fmt.Errorf("unexpected output from 'gerrit version': %q", stdout)
+	}
+	return fields[2], nil
+}
+
+// dialSSH connects to address using config. Both the connection and
+// the ssh handshake are bound by ctx.
+func dialSSH(ctx context.Context, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close() // nolint: errcheck
+			return nil, err
+		}
+	}
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close() // nolint: errcheck
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		clientConn.Close() // nolint: errcheck
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, channels, requests), nil
 }
 
 // NewSSHClient produces an *SSHClient struct and attempts to connect to
 // Gerrrit.
 func NewSSHClient(config *Config, port *dockertest.Port) (*SSHClient, error) {
+	return newSSHClient(context.Background(), config, port)
+}
+
+// newSSHClient is similar to NewSSHClient except connecting and
+// retrieving the version of Gerrit are bound by ctx.
+func newSSHClient(ctx context.Context, config *Config, port *dockertest.Port) (*SSHClient, error) {
 	logger := log.WithFields(log.Fields{
 		"svc": "gerrittest",
 		"cmp": "SSHPort",
@@ -77,17 +231,18 @@ func NewSSHClient(config *Config, port *dockertest.Port) (*SSHClient, error) {
 		return nil, errors.New("no ssh keys present")
 	}
 
+	address := fmt.Sprintf("%s:%d", port.Address, port.Public)
 	for _, key := range config.SSHKeys {
-		sshClient, err := ssh.Dial(
-			"tcp", fmt.Sprintf("%s:%d", port.Address, port.Public),
-			&ssh.ClientConfig{
-				User:            config.Username,
-				Auth:            []ssh.AuthMethod{ssh.PublicKeys(key.Private)},
-				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-			},
-		)
+		sshClient, err := dialSSH(ctx, address, &ssh.ClientConfig{
+			User:            config.Username,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(key.Private)},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		})
 		if err != nil {
 			logger.WithError(err).Warn()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			continue
 		}
 
@@ -95,7 +250,7 @@ func NewSSHClient(config *Config, port *dockertest.Port) (*SSHClient, error) {
 			log:    logger,
 			Client: sshClient,
 		}
-		version, err := client.Version()
+		version, err := client.version(ctx)
 		logger.WithField("version", version).Debug()
 		return client, err
 	}
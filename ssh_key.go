@@ -93,6 +93,31 @@ func (s *SSHKey) load() error {
 	return err
 }
 
+// copyTo copies the private key to a new temporary file whose name
+// starts with prefix and returns an *SSHKey for the copy. The copy is not
+// considered to be generated so Remove() will not remove it.
+func (s *SSHKey) copyTo(prefix string) (*SSHKey, error) {
+	private, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() // nolint: errcheck
+	if _, err := file.Write(private); err != nil {
+		return nil, err
+	}
+	return &SSHKey{
+		Public:    s.Public,
+		Private:   s.Private,
+		Path:      file.Name(),
+		Generated: false,
+		Default:   s.Default,
+	}, nil
+}
+
 // String outputs a useful string representing the struct.
 func (s *SSHKey) String() string {
 	return fmt.Sprintf(
@@ -126,8 +151,7 @@ func NewSSHKey() (*SSHKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	file, err := ioutil.TempFile(
-		"", fmt.Sprintf("%s-id_rsa-", ProjectName))
+	file, err := ioutil.TempFile("", tempPrefix("id_rsa"))
 	if err != nil {
 		return nil, err
 	}
@@ -141,3 +165,26 @@ func NewSSHKey() (*SSHKey, error) {
 	}
 	return key, key.load()
 }
+
+// CreateSSHKey generates a new key and writes it to the provided path. Unlike
+// NewSSHKey() the key is not considered to be generated so Remove() will
+// not delete it.
+func CreateSSHKey(path string) (*SSHKey, error) {
+	generated, err := GenerateRSAKey()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteRSAKey(generated, file); err != nil {
+		return nil, err
+	}
+	key := &SSHKey{
+		Path:      path,
+		Generated: false,
+		Default:   true,
+	}
+	return key, key.load()
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"golang.org/x/crypto/ssh"
 	. "gopkg.in/check.v1"
@@ -110,3 +111,18 @@ func (s *SSHKeyTest) TestSSHKeyString(c *C) {
 		"SSHKey{path: %s, generated: %t, default: %t}",
 		key.Path, key.Generated, key.Default))
 }
+
+func (s *SSHKeyTest) TestCreateSSHKey(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "id_rsa")
+	key, err := CreateSSHKey(path)
+	c.Assert(err, IsNil)
+	c.Assert(key.Path, Equals, path)
+	c.Assert(key.Private, NotNil)
+	c.Assert(key.Remove(), IsNil)
+	_, err = os.Stat(path)
+	c.Assert(err, IsNil)
+
+	_, err = CreateSSHKey(path)
+	c.Assert(os.IsExist(err), Equals, true)
+}
@@ -0,0 +1,139 @@
+package gerrittest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/opalmer/dockertest"
+	"golang.org/x/crypto/ssh"
+	. "gopkg.in/check.v1"
+)
+
+type SSHTest struct {
+	listener net.Listener
+	client   *SSHClient
+}
+
+var _ = Suite(&SSHTest{})
+
+// handleSession responds to exec requests. 'gerrit version' prints a
+// version, 'exit N' exits with status N and any other command echos
+// stdin to stdout and the command to stderr.
+func (s *SSHTest) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close() // nolint: errcheck
+	for request := range requests {
+		switch request.Type {
+		case "pty-req":
+			request.Reply(true, nil) // nolint: errcheck
+		case "exec":
+			request.Reply(true, nil) // nolint: errcheck
+			length := binary.BigEndian.Uint32(request.Payload)
+			command := string(request.Payload[4 : 4+length])
+			code := 0
+			switch {
+			case command == "gerrit version":
+				channel.Write([]byte("gerrit version 2.14.5.1\n")) // nolint: errcheck
+			case strings.HasPrefix(command, "exit "):
+				code, _ = strconv.Atoi(strings.TrimPrefix(command, "exit ")) // nolint: errcheck
+			default:
+				buffer := &bytes.Buffer{}
+				buffer.ReadFrom(channel)                // nolint: errcheck
+				channel.Write(buffer.Bytes())           // nolint: errcheck
+				channel.Stderr().Write([]byte(command)) // nolint: errcheck
+			}
+			status := make([]byte, 4)
+			binary.BigEndian.PutUint32(status, uint32(code))
+			channel.SendRequest("exit-status", false, status) // nolint: errcheck
+			return
+		default:
+			request.Reply(false, nil) // nolint: errcheck
+		}
+	}
+}
+
+func (s *SSHTest) SetUpTest(c *C) {
+	key, err := GenerateRSAKey()
+	c.Assert(err, IsNil)
+	signer, err := ssh.NewSignerFromKey(key)
+	c.Assert(err, IsNil)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(requests)
+				for newChannel := range channels {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go s.handleSession(channel, requests)
+				}
+			}()
+		}
+	}()
+
+	sshKey, err := CreateSSHKey(filepath.Join(c.MkDir(), "id_rsa"))
+	c.Assert(err, IsNil)
+	cfg := NewConfig()
+	cfg.SSHKeys = []*SSHKey{sshKey}
+	split := strings.Split(s.listener.Addr().String(), ":")
+	port, err := strconv.ParseUint(split[1], 10, 16)
+	c.Assert(err, IsNil)
+	s.client, err = NewSSHClient(cfg, &dockertest.Port{Address: split[0], Public: uint16(port)})
+	c.Assert(err, IsNil)
+}
+
+func (s *SSHTest) TearDownTest(c *C) {
+	c.Assert(s.client.Close(), IsNil)
+	c.Assert(s.listener.Close(), IsNil)
+}
+
+func (s *SSHTest) TestVersion(c *C) {
+	version, err := s.client.Version()
+	c.Assert(err, IsNil)
+	c.Assert(version, Equals, "2.14.5.1")
+}
+
+func (s *SSHTest) TestStream(c *C) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code, err := s.client.Stream(
+		"gerrit ls-projects", strings.NewReader("hello"), stdout, stderr)
+	c.Assert(err, IsNil)
+	c.Assert(code, Equals, 0)
+	c.Assert(stdout.String(), Equals, "hello")
+	c.Assert(stderr.String(), Equals, "gerrit ls-projects")
+}
+
+func (s *SSHTest) TestStream_exitStatus(c *C) {
+	code, err := s.client.Stream(
+		"exit 3", strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+	c.Assert(err, IsNil)
+	c.Assert(code, Equals, 3)
+}
+
+func (s *SSHTest) TestRun_exitStatus(c *C) {
+	_, _, err := s.client.Run("exit 2")
+	exitErr, ok := err.(*ssh.ExitError)
+	c.Assert(ok, Equals, true)
+	c.Assert(exitErr.ExitStatus(), Equals, 2)
+}
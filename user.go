@@ -0,0 +1,255 @@
+package gerrittest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-gerrit"
+	"github.com/crewjam/errset"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrUsernameNotProvided is returned by CreateUser if no
+// username was provided.
+var ErrUsernameNotProvided = errors.New("username not provided")
+
+// UserOptions contains optional settings for Gerrit.CreateUser().
+type UserOptions struct {
+	// FullName is the full name of the user. Defaults to the username.
+	FullName string
+
+	// Password is the user's http password. If not provided one will
+	// be randomly generated.
+	Password string
+
+	// Groups contains the names of existing groups the user
+	// should be added to.
+	Groups []string
+}
+
+// User is an account created by Gerrit.CreateUser(). Each user has their
+// own ssh key, http password and clients.
+type User struct {
+	// Username is the username of the account.
+	Username string
+
+	// Email is the preferred email address of the account.
+	Email string
+
+	// Config is a copy of the *Gerrit's config with the username,
+	// password, ssh key and git config replaced by the user's.
+	Config *Config
+
+	// SSHKey is the generated ssh key for the user.
+	SSHKey *SSHKey
+
+	// HTTP and SSH are clients authenticated as the user.
+	HTTP *HTTPClient
+	SSH  *SSHClient
+}
+
+// NewRepository returns a new *Repository which will commit and push
+// as the user. The caller is responsible for destroying the repository.
+func (u *User) NewRepository() (*Repository, error) {
+	return NewRepository(u.Config)
+}
+
+// Destroy closes the user's ssh connection and removes the user's ssh
+// key from disk. The account will continue to exist in Gerrit.
+func (u *User) Destroy() error {
+	errs := errset.ErrSet{}
+	if u.SSH != nil {
+		errs = append(errs, u.SSH.Close())
+	}
+	if u.SSHKey != nil && u.SSHKey.Generated {
+		errs = append(errs, os.Remove(u.SSHKey.Path))
+	}
+	return errs.ReturnValue()
+}
+
+// randomPassword returns a random http password. Unlike
+// HTTPClient.generatePassword() this does not require the account
+// to exist yet.
+func randomPassword() (string, error) {
+	data := make([]byte, 18)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// newUserConfig returns a copy of cfg for the provided user.
+func newUserConfig(cfg *Config, username string, email string, password string, key *SSHKey) *Config {
+	copied := *cfg
+	copied.Username = username
+	copied.Password = password
+	copied.SSHKeys = []*SSHKey{key}
+	copied.GitConfig = mergeMaps(cfg.GitConfig, map[string]string{
+		"user.name":       username,
+		"user.email":      email,
+		"core.sshCommand": gitSSHCommand(key),
+	})
+	return &copied
+}
+
+// CreateUser creates a new account along with an ssh key and http
+// password and returns a *User with clients authenticated as the new
+// account. If the username is already taken, for example by an account
+// deactivated by Reset(), the existing account is reactivated and its
+// name, email, password, ssh keys and groups are replaced. opts may be nil. The caller should call User.Destroy() once
+// the user is no longer needed.
+func (g *Gerrit) CreateUser(username string, email string, opts *UserOptions) (*User, error) { // nolint: gocyclo
+	if username == "" {
+		return nil, ErrUsernameNotProvided
+	}
+	if opts == nil {
+		opts = &UserOptions{}
+	}
+	logger := g.log.WithFields(log.Fields{
+		"phase":    "create-user",
+		"username": username,
+	})
+	client, err := g.HTTP.Gerrit()
+	if err != nil {
+		return nil, err
+	}
+
+	password := opts.Password
+	if password == "" {
+		password, err = randomPassword()
+		if err != nil {
+			return nil, err
+		}
+	}
+	name := opts.FullName
+	if name == "" {
+		name = username
+	}
+
+	logger.WithField("action", "ssh-key").Debug()
+	key, err := NewSSHKey()
+	if err != nil {
+		return nil, err
+	}
+	user := &User{
+		Username: username,
+		Email:    email,
+		Config:   newUserConfig(g.Config, username, email, password, key),
+		SSHKey:   key,
+	}
+
+	logger.WithField("action", "create-account").Debug()
+	input := &gerrit.AccountInput{
+		Username:     username,
+		Name:         name,
+		Email:        email,
+		SSHKey:       strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key.Public))),
+		HTTPPassword: password,
+		Groups:       opts.Groups,
+	}
+	_, response, err := client.Accounts.CreateAccount(username, input)
+	if err != nil && response != nil && response.StatusCode == http.StatusConflict {
+		logger.WithField("action", "reuse-account").Debug()
+		err = g.reuseAccount(client, input)
+	}
+	if err != nil {
+		user.Destroy() // nolint: errcheck
+		return nil, err
+	}
+
+	logger.WithField("action", "clients").Debug()
+	user.HTTP, err = NewHTTPClient(user.Config, g.HTTPPort)
+	if err != nil {
+		user.Destroy() // nolint: errcheck
+		return nil, err
+	}
+	user.SSH, err = NewSSHClient(user.Config, g.SSHPort)
+	if err != nil {
+		user.Destroy() // nolint: errcheck
+		return nil, err
+	}
+	return user, nil
+}
+
+// reuseAccount reactivates the existing account for input.Username and
+// updates it to match input. Existing ssh keys are removed.
+func (g *Gerrit) reuseAccount(client *gerrit.Client, input *gerrit.AccountInput) error { // nolint: gocyclo
+	info, _, err := client.Accounts.GetAccount(input.Username)
+	if err != nil {
+		return err
+	}
+	id := strconv.Itoa(info.AccountID)
+	if _, err := client.Accounts.SetActive(id); err != nil {
+		return err
+	}
+	if _, _, err := client.Accounts.SetAccountName(id, &gerrit.AccountNameInput{Name: input.Name}); err != nil {
+		return err
+	}
+	if input.Email != "" {
+		if err := g.setAccountEmail(client, id, input.Email); err != nil {
+			return err
+		}
+	}
+	if _, _, err := client.Accounts.SetHTTPPassword(id, &gerrit.HTTPPasswordInput{HTTPPassword: input.HTTPPassword}); err != nil {
+		return err
+	}
+
+	keys, _, err := client.Accounts.ListSSHKeys(id)
+	if err != nil {
+		return err
+	}
+	for _, key := range *keys {
+		if _, err := client.Accounts.DeleteSSHKey(id, strconv.Itoa(key.Seq)); err != nil {
+			return err
+		}
+	}
+	body, err := json.Marshal(input.SSHKey)
+	if err != nil {
+		return err
+	}
+	path := "/accounts/" + id + "/sshkeys"
+	response, _, err := g.HTTP.API(http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusCreated {
+		return fmt.Errorf("POST %s: %s", path, response.Status)
+	}
+
+	for _, group := range input.Groups {
+		if _, _, err := client.Groups.AddGroupMember(url.PathEscape(group), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setAccountEmail makes email the preferred email of account id, adding
+// it first if needed.
+func (g *Gerrit) setAccountEmail(client *gerrit.Client, id string, email string) error {
+	emails, _, err := client.Accounts.ListAccountEmails(id)
+	if err != nil {
+		return err
+	}
+	for _, info := range *emails {
+		if info.Email == email {
+			_, err := client.Accounts.SetPreferredEmail(id, email)
+			return err
+		}
+	}
+	_, _, err = client.Accounts.CreateAccountEmail(id, email, &gerrit.EmailInput{
+		Email:          email,
+		Preferred:      true,
+		NoConfirmation: true,
+	})
+	return err
+}
@@ -0,0 +1,72 @@
+package gerrittest
+
+import (
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type UserTest struct{}
+
+var _ = Suite(&UserTest{})
+
+func (s *UserTest) TestRandomPassword(c *C) {
+	first, err := randomPassword()
+	c.Assert(err, IsNil)
+	second, err := randomPassword()
+	c.Assert(err, IsNil)
+	c.Assert(first, HasLen, 24)
+	c.Assert(first, Not(Equals), second)
+}
+
+func (s *UserTest) TestNewUserConfig(c *C) {
+	key, err := CreateSSHKey(filepath.Join(c.MkDir(), "id_rsa"))
+	c.Assert(err, IsNil)
+	cfg := NewConfig()
+	cfg.GitConfig["core.sshCommand"] = "ssh"
+	userCfg := newUserConfig(cfg, "jdoe", "jdoe@localhost", "secret", key)
+	c.Assert(userCfg.Username, Equals, "jdoe")
+	c.Assert(userCfg.Password, Equals, "secret")
+	c.Assert(userCfg.SSHKeys, DeepEquals, []*SSHKey{key})
+	c.Assert(userCfg.GitConfig["user.name"], Equals, "jdoe")
+	c.Assert(userCfg.GitConfig["user.email"], Equals, "jdoe@localhost")
+	c.Assert(userCfg.GitConfig["core.sshCommand"], Equals, gitSSHCommand(key))
+
+	// The original config should not be modified.
+	c.Assert(cfg.Username, Equals, NewConfig().Username)
+	c.Assert(cfg.GitConfig["core.sshCommand"], Equals, "ssh")
+}
+
+func (s *UserTest) TestCreateUser_noUsername(c *C) {
+	_, err := (&Gerrit{}).CreateUser("", "", nil)
+	c.Assert(err, Equals, ErrUsernameNotProvided)
+}
+
+func (s *UserTest) TestCreateUser(c *C) {
+	if testing.Short() {
+		c.Skip("-short provided")
+	}
+	g, err := New(NewConfig())
+	c.Assert(err, IsNil)
+	defer g.Destroy() // nolint: errcheck
+
+	user, err := g.CreateUser("jdoe", "jdoe@localhost", nil)
+	c.Assert(err, IsNil)
+	defer user.Destroy() // nolint: errcheck
+
+	client, err := user.HTTP.Gerrit()
+	c.Assert(err, IsNil)
+	account, _, err := client.Accounts.GetAccount("self")
+	c.Assert(err, IsNil)
+	c.Assert(account.Username, Equals, "jdoe")
+	_, err = user.SSH.Version()
+	c.Assert(err, IsNil)
+
+	repo, err := user.NewRepository()
+	c.Assert(err, IsNil)
+	defer repo.Destroy() // nolint: errcheck
+	c.Assert(repo.AddOriginFromContainer(g.Container, ProjectName), IsNil)
+	c.Assert(repo.Commit("Add foo"), IsNil)
+	c.Assert(repo.Push("HEAD:refs/for/master"), IsNil)
+}